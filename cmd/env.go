@@ -0,0 +1,107 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+// the hidden command run by the istioctl shim
+const istioctlShimCmdName = "istioctl-shim"
+
+func newEnvCmd(homedir string) *cobra.Command {
+	var shell string
+	var installShim bool
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Print the commands to set up the shell for getmesh and the istioctl shim",
+		Long: `Print the commands to set up the shell for getmesh and the istioctl shim
+
+With --install-shim, the istioctl shim is installed in the bin directory of the getmesh home. Once the directory is
+on PATH, plain "istioctl" runs the active istioctl, respecting GETMESH_ISTIO_VERSION and .getmesh-version as
+"getmesh istioctl" does. Note that the shim takes precedence over the other istioctl on PATH after the directory.
+The shim is never installed by the other commands.`,
+		Example: `# Set up the current shell
+$ eval "$(getmesh env)"
+
+# Install the istioctl shim and set up the current shell
+$ eval "$(getmesh env --install-shim)"
+
+# Set up the current fish shell
+$ getmesh env --shell fish | source`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if installShim {
+				if err := istioctl.InstallShim(homedir); err != nil {
+					return fmt.Errorf("failed to install the istioctl shim: %v", err)
+				}
+			}
+			return printEnv(cmd.OutOrStdout(), homedir, shell)
+		},
+	}
+	cmd.Flags().StringVarP(&shell, "shell", "", "sh", "Syntax of the printed commands, \"sh\" for POSIX shells or \"fish\"")
+	cmd.Flags().BoolVarP(&installShim, "install-shim", "", false,
+		"Install the istioctl shim running the active istioctl in the bin directory of the getmesh home")
+	return cmd
+}
+
+func printEnv(w io.Writer, homedir, shell string) error {
+	bin := istioctl.GetBinDir(homedir)
+	switch shell {
+	case "sh", "bash", "zsh":
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`")
+		fmt.Fprintf(w, "export GETMESH_HOME=\"%s\"\n", r.Replace(homedir))
+		fmt.Fprintf(w, "export PATH=\"%s:$PATH\"\n", r.Replace(bin))
+	case "fish":
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+		fmt.Fprintf(w, "set -gx GETMESH_HOME \"%s\"\n", r.Replace(homedir))
+		fmt.Fprintf(w, "set -gx PATH \"%s\" $PATH\n", r.Replace(bin))
+	default:
+		return fmt.Errorf("unsupported shell %s: must be sh or fish", shell)
+	}
+	return nil
+}
+
+func newIstioctlShimCmd(homedir string) *cobra.Command {
+	return &cobra.Command{
+		Use:    istioctlShimCmdName,
+		Hidden: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return istioctl.ExecShim(homedir, args)
+		},
+		// the arguments are passed to istioctl as they are
+		DisableFlagParsing: true,
+	}
+}
+
+// ExecuteIstioctlShim runs the active istioctl with the arguments, called when getmesh is invoked as the istioctl shim.
+func ExecuteIstioctlShim(version, homeDir string, args []string) {
+	// keep the stdout of istioctl intact for the scripts parsing it
+	logger.SetWriter(os.Stderr)
+
+	cmd := NewRoot(version, homeDir)
+	cmd.SetArgs(append([]string{istioctlShimCmdName}, args...))
+	if err := cmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
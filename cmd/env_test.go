@@ -0,0 +1,98 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+)
+
+func Test_printEnv(t *testing.T) {
+	for _, c := range []struct {
+		shell, homedir, exp string
+	}{
+		{
+			shell:   "sh",
+			homedir: "/home/user/.getmesh",
+			exp: `export GETMESH_HOME="/home/user/.getmesh"
+export PATH="/home/user/.getmesh/bin:$PATH"
+`,
+		},
+		{
+			shell:   "bash",
+			homedir: "/home/$user/my \"getmesh\"",
+			exp: `export GETMESH_HOME="/home/\$user/my \"getmesh\""
+export PATH="/home/\$user/my \"getmesh\"/bin:$PATH"
+`,
+		},
+		{
+			shell:   "fish",
+			homedir: "/home/user/.getmesh",
+			exp: `set -gx GETMESH_HOME "/home/user/.getmesh"
+set -gx PATH "/home/user/.getmesh/bin" $PATH
+`,
+		},
+	} {
+		t.Run(c.shell, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, printEnv(buf, c.homedir, c.shell))
+			require.Equal(t, c.exp, buf.String())
+		})
+	}
+
+	require.Error(t, printEnv(new(bytes.Buffer), "/home/user/.getmesh", "powershell"))
+}
+
+func TestEnv_installShim(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	home := t.TempDir()
+	require.NoError(t, getmesh.SetIstioVersion(home, nil))
+	shim := filepath.Join(istioctl.GetBinDir(home), istioctl.ShimName)
+	run := func(args ...string) error {
+		cmd := NewRoot("dev", home)
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetArgs(append([]string{"env"}, args...))
+		return cmd.Execute()
+	}
+
+	t.Run("not installed by default", func(t *testing.T) {
+		require.NoError(t, run())
+		_, err := os.Lstat(shim)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("install", func(t *testing.T) {
+		require.NoError(t, run("--install-shim"))
+		info, err := os.Lstat(shim)
+		require.NoError(t, err)
+		require.NotZero(t, info.Mode()&os.ModeSymlink)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		// the istioctl not managed by getmesh is never replaced
+		require.NoError(t, os.Remove(shim))
+		require.NoError(t, os.WriteFile(shim, nil, 0755))
+		require.Error(t, run("--install-shim"))
+	})
+}
@@ -16,9 +16,10 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"runtime"
 	"strings"
 
-	"github.com/Masterminds/semver"
 	"github.com/spf13/cobra"
 
 	"github.com/tetratelabs/getmesh/internal/istioctl"
@@ -30,8 +31,16 @@ import (
 type fetchFlags struct {
 	name, version, flavor string
 	flavorVersion         int64
+
+	names       []string
+	file        string
+	parallelism int
+
+	goos, goarch, outputDir string
 }
 
+const defaultFetchParallelism = 4
+
 func newFetchCmd(homedir string) *cobra.Command {
 	var flag fetchFlags
 
@@ -45,6 +54,12 @@ $ getmesh fetch --version 1.8
 # Fetch the latest istioctl in version=1.7 and flavor=tetratefips
 $ getmesh fetch --version 1.7 --flavor tetratefips
 
+# Fetch the latest "tetratefips flavored" istioctl of the patch versions after 1.18.2 in version=1.18
+$ getmesh fetch --version "~1.18.2" --flavor tetratefips
+
+# Fetch the latest "tetrate flavored" istioctl in the range
+$ getmesh fetch --version ">=1.17.5 <1.19"
+
 # Fetch the latest istioctl of version=1.7, flavor=tetrate and flavor-version=0
 $ getmesh fetch --version 1.7 --flavor tetrate --flavor-version 0
 
@@ -68,16 +83,35 @@ $ getmesh fetch --version 1.8.3 --flavor istio
 # Fetch the latest "tetrate flavored" istioctl
 $ getmesh fetch
 
+# Fetch multiple istioctl concurrently
+$ getmesh fetch --name 1.9.0-istio-v0 --name 1.8.3-tetrate-v0
+
+# Fetch the istioctl listed in the file, one distribution name per line
+$ getmesh fetch --file distributions.txt
+
+# Download the release archive for macOS on arm64 into the bundle directory, without installing it
+$ getmesh fetch --name 1.9.0-tetrate-v0 --os darwin --arch arm64 --output-dir bundle
+
 As you can see the above examples:
 - If --flavor-versions is not given, it defaults to the latest flavor version in the list
-	If the value does not have patch version, "1.7" or "1.8" for example, then we fallback to the latest patch version in that minor version. 
+	If the value does not have patch version, "1.7" or "1.8" for example, then we fallback to the latest patch version in that minor version.
+	If the value is a constraint such as "~1.18", "^1.18" or ">=1.17.5 <1.19", then we fallback to the latest version satisfying it.
 - If --flavor is not given, it defaults to "tetrate" flavor.
 - If --versions is not given, it defaults to the latest version of "tetrate" flavor.
+- If multiple distributions are given by --name or --file, they are fetched concurrently
+	and none of them is activated, even when no istioctl is active yet.
+- If --output-dir is given, the release archives are downloaded there instead of being installed,
+	which is required for the platforms given by --os and --arch other than the running one.
+	The archive can be installed on the target machine by "getmesh import".
 
 
 For more information, please refer to "getmesh list --help" command.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := fetchCheckPlatform(&flag); err != nil {
+				return err
+			}
+
 			ms, err := manifest.FetchManifest()
 			if err != nil {
 				return fmt.Errorf("error fetching manifest: %v", err)
@@ -87,11 +121,20 @@ For more information, please refer to "getmesh list --help" command.
 				return err
 			}
 
-			d, err := fetchParams(&flag, ms)
+			ds, err := fetchTargets(&flag, ms)
 			if err != nil {
 				return err
 			}
 
+			if len(flag.outputDir) != 0 {
+				return fetchArchives(homedir, ds, ms, &flag)
+			}
+
+			if len(ds) > 1 {
+				return fetchMultiple(homedir, ds, ms, flag.parallelism)
+			}
+
+			d := ds[0]
 			err = istioctl.Fetch(homedir, d, ms)
 			if err != nil {
 				return err
@@ -107,88 +150,182 @@ For more information, please refer to "getmesh list --help" command.
 					d.String(), notes)
 			}
 
+			// switchExec locks the home directory by itself
 			return switchExec(homedir, d)
 		},
 	}
 
 	flags := cmd.Flags()
 	flags.SortFlags = false
-	flags.StringVarP(&flag.name, "name", "", "", "Name of distribution, e.g. 1.9.0-istio-v0")
-	flags.StringVarP(&flag.version, "version", "", "", "Version of istioctl e.g. \"--version 1.7.4\". When --name flag is set, this will not be used.")
+	flags.StringSliceVarP(&flag.names, "name", "", nil,
+		"Name of distribution, e.g. 1.9.0-istio-v0. This can be repeated to fetch multiple distributions")
+	flags.StringVarP(&flag.file, "file", "", "",
+		"Path to the file listing the names of distributions to fetch, one per line. Lines starting with \"#\" are ignored")
+	flags.IntVarP(&flag.parallelism, "parallelism", "", defaultFetchParallelism,
+		"Maximum number of distributions fetched concurrently")
+	flags.StringVarP(&flag.version, "version", "", "", "Version of istioctl e.g. \"--version 1.7.4\", or the constraint e.g. \"--version ~1.18\". When --name flag is set, this will not be used.")
 	flags.StringVarP(&flag.flavor, "flavor", "", "",
 		"Flavor of istioctl, e.g. \"--flavor tetrate\" or --flavor tetratefips\" or --flavor istio\". When --name flag is set, this will not be used.")
 	flags.Int64VarP(&flag.flavorVersion, "flavor-version", "", -1,
 		"Version of the flavor, e.g. \"--version 1\". When --name flag is set, this will not be used.")
+	flags.StringVarP(&flag.goos, "os", "", "", "OS of the release archive, e.g. linux or darwin. Defaults to the running one")
+	flags.StringVarP(&flag.goarch, "arch", "", "", "Architecture of the release archive, e.g. amd64 or arm64. Defaults to the running one")
+	flags.StringVarP(&flag.outputDir, "output-dir", "", "",
+		"Directory to download the release archives into, instead of installing them")
 	return cmd
 }
 
+// fetchCheckPlatform defaults --os and --arch to the running platform, and requires --output-dir for the others
+// since istioctl of the other platforms cannot be installed
+func fetchCheckPlatform(flags *fetchFlags) error {
+	if len(flags.goos) == 0 {
+		flags.goos = runtime.GOOS
+	}
+	if len(flags.goarch) == 0 {
+		flags.goarch = runtime.GOARCH
+	}
+	if len(flags.outputDir) == 0 && (flags.goos != runtime.GOOS || flags.goarch != runtime.GOARCH) {
+		return fmt.Errorf("--output-dir must be given to fetch for %s/%s", flags.goos, flags.goarch)
+	}
+	return nil
+}
+
+// fetchArchives downloads the release archives into the output dir one by one
+func fetchArchives(homedir string, ds []*manifest.IstioDistribution, ms *manifest.Manifest, flags *fetchFlags) error {
+	var failed int
+	for _, d := range ds {
+		p, err := istioctl.DownloadArchive(homedir, d, ms, flags.goos, flags.goarch, flags.outputDir)
+		if err != nil {
+			logger.Warnf("failed to download %s: %v\n", d.String(), err)
+			failed++
+			continue
+		}
+		logger.Infof("%s for %s/%s downloaded to %s\n", d.String(), flags.goos, flags.goarch, p)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to download %d of %d distributions", failed, len(ds))
+	}
+	return nil
+}
+
+// fetchTargets returns the distributions given by --name and --file, or the one specified by the other flags
+func fetchTargets(flags *fetchFlags, ms *manifest.Manifest) ([]*manifest.IstioDistribution, error) {
+	names := flags.names
+	if len(flags.file) != 0 {
+		fromFile, err := readDistributionNames(flags.file)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, fromFile...)
+	}
+
+	if len(names) == 0 {
+		d, err := fetchParams(flags, ms)
+		if err != nil {
+			return nil, err
+		}
+		return []*manifest.IstioDistribution{d}, nil
+	}
+
+	var (
+		ret  = make([]*manifest.IstioDistribution, 0, len(names))
+		seen = make(map[string]struct{}, len(names))
+	)
+	for _, name := range names {
+		d, err := fetchParams(&fetchFlags{name: name}, ms)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[d.String()]; ok {
+			continue
+		}
+		seen[d.String()] = struct{}{}
+		ret = append(ret, d)
+	}
+	return ret, nil
+}
+
+func readDistributionNames(path string) ([]string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	var ret []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ret = append(ret, line)
+	}
+	return ret, nil
+}
+
+func fetchMultiple(homedir string, ds []*manifest.IstioDistribution, ms *manifest.Manifest, parallelism int) error {
+	errs := istioctl.FetchAll(homedir, ds, ms, parallelism)
+
+	var failed int
+	logger.Infof("\nSummary:\n")
+	for i, d := range ds {
+		if errs[i] != nil {
+			failed++
+			logger.Infof("- %s: failed: %v\n", d.String(), errs[i])
+		} else {
+			logger.Infof("- %s: ok\n", d.String())
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to fetch %d of %d distributions", failed, len(ds))
+	}
+	return nil
+}
+
 func fetchParams(flags *fetchFlags,
 	ms *manifest.Manifest) (*manifest.IstioDistribution, error) {
 	if len(flags.name) != 0 {
 		d, err := manifest.IstioDistributionFromString(flags.name)
 		if err != nil {
 			return nil, fmt.Errorf("cannot parse given name %s to istio distribution", flags.name)
+		} else if len(d.Variant) != 0 {
+			return nil, fmt.Errorf("the image variant %s in %s does not apply to istioctl", d.Variant, flags.name)
 		}
 		return d, nil
 	}
-	if flags.flavor != manifest.IstioDistributionFlavorTetrate &&
-		flags.flavor != manifest.IstioDistributionFlavorTetrateFIPS &&
-		flags.flavor != manifest.IstioDistributionFlavorIstio {
+	if !manifest.IsManagedFlavor(flags.flavor) {
 		flags.flavor = manifest.IstioDistributionFlavorTetrate
 		logger.Infof("fallback to the %s flavor since --flavor flag is not given or not supported\n", flags.flavor)
 	}
 	if len(flags.version) == 0 {
-		for _, m := range ms.IstioDistributions {
-			if m.Flavor == flags.flavor {
-				return m, nil
-			}
+		latest, err := manifest.GetLatestDistributionInFlavor(flags.flavor, ms)
+		if err != nil {
+			return nil, err
+		} else if latest != nil {
+			return latest, nil
 		}
 	}
 
 	ret := &manifest.IstioDistribution{Version: flags.version, Flavor: flags.flavor, FlavorVersion: flags.flavorVersion}
 
-	if strings.Count(flags.version, ".") == 1 {
-		// In the case where patch version is not given,
-		// we find the latest patch version
-		var (
-			latest *manifest.IstioDistribution
-			prev   *semver.Version
-		)
-
-		v, err := semver.NewVersion(flags.version)
+	if _, err := manifest.ParseVersion(flags.version); err != nil {
+		// In the case where the exact version is not given, such as "1.7" or "~1.18",
+		// we find the latest version satisfying it
+		version, err := resolveVersionConstraint(flags.version, ret.Flavor, ms)
 		if err != nil {
 			return nil, err
 		}
-
-		for _, d := range ms.IstioDistributions {
-			cur, err := semver.NewVersion(d.Version)
-			if err != nil {
-				return nil, err
-			}
-
-			if d.Flavor == ret.Flavor && cur.Minor() == v.Minor() && (prev == nil || cur.GreaterThan(prev)) {
-				prev = cur
-				latest = d
-			}
-		}
-
-		if latest == nil {
-			return nil, fmt.Errorf("invalid version %s", ret.Version)
-		}
-
-		ret.Version = latest.Version
-		logger.Infof("fallback to %s which is the latest patch version in the given verion minor %s\n",
-			ret.Version, flags.version)
+		ret.Version = version
 	}
 
 	if ret.FlavorVersion < 0 {
 		// search the latest flavor version in this flavor
 		var found bool
 		for _, m := range ms.IstioDistributions {
-			if m.Version == ret.Version && m.Flavor == ret.Flavor {
+			if m.Version == ret.Version && m.Flavor == ret.Flavor && (!found || m.FlavorVersion > ret.FlavorVersion) {
 				ret.FlavorVersion = m.FlavorVersion
 				found = true
-				break
 			}
 		}
 		if !found {
@@ -200,3 +337,21 @@ func fetchParams(flags *fetchFlags,
 
 	return ret, nil
 }
+
+// resolve the version constraint, e.g. "1.18", "~1.18" or ">=1.17.5 <1.19", to the latest version of the flavor in the manifest
+func resolveVersionConstraint(constraint, flavor string, ms *manifest.Manifest) (string, error) {
+	c, err := manifest.ParseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	latest, err := manifest.GetLatestDistributionMatching(c, flavor, ms)
+	if err != nil {
+		return "", err
+	} else if latest == nil {
+		return "", fmt.Errorf("no %s distribution satisfies the version %s in the manifest", flavor, constraint)
+	}
+
+	logger.Infof("fallback to %s which is the latest version satisfying %s\n", latest.Version, constraint)
+	return latest.Version, nil
+}
@@ -16,6 +16,9 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -135,6 +138,83 @@ func Test_fetchParams(t *testing.T) {
 			},
 			exp: &manifest.IstioDistribution{Version: "1.7.100", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
 		},
+		{
+			// version not given -> the latest release across minor versions regardless of the order in the manifest
+			flag: &fetchFlags{flavorVersion: -1},
+			mf: &manifest.Manifest{
+				IstioDistributions: []*manifest.IstioDistribution{
+					{Version: "1.9.3", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.20.0-beta.1", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.10.1", FlavorVersion: 1, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.10.1", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+				},
+			},
+			exp: &manifest.IstioDistribution{Version: "1.10.1", FlavorVersion: 1, Flavor: manifest.IstioDistributionFlavorTetrate},
+		},
+		{
+			// patch version not given -> pre-releases and the other major versions are ignored
+			flag: &fetchFlags{version: "1.20", flavorVersion: 0},
+			mf: &manifest.Manifest{
+				IstioDistributions: []*manifest.IstioDistribution{
+					{Version: "2.20.3", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.20.1-rc.0", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.20.0", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+				},
+			},
+			exp: &manifest.IstioDistribution{Version: "1.20.0", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+		},
+		{
+			// flavorVersion not given -> the latest flavor version regardless of the order in the manifest
+			flag: &fetchFlags{version: "1.20.0-beta.1", flavorVersion: -1},
+			mf: &manifest.Manifest{
+				IstioDistributions: []*manifest.IstioDistribution{
+					{Version: "1.20.0-beta.1", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.20.0-beta.1", FlavorVersion: 2, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.20.0-beta.1", FlavorVersion: 1, Flavor: manifest.IstioDistributionFlavorTetrate},
+				},
+			},
+			exp: &manifest.IstioDistribution{Version: "1.20.0-beta.1", FlavorVersion: 2, Flavor: manifest.IstioDistributionFlavorTetrate},
+		},
+		{
+			// invalid version
+			flag: &fetchFlags{version: "1.7.x.1", flavorVersion: 0},
+			mf:   &manifest.Manifest{},
+		},
+		{
+			// constraint -> the latest version satisfying it in the flavor
+			flag: &fetchFlags{version: "~1.18.2", flavor: manifest.IstioDistributionFlavorTetrateFIPS, flavorVersion: -1},
+			mf: &manifest.Manifest{
+				IstioDistributions: []*manifest.IstioDistribution{
+					{Version: "1.19.0", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrateFIPS},
+					{Version: "1.18.5", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.18.3", FlavorVersion: 1, Flavor: manifest.IstioDistributionFlavorTetrateFIPS},
+					{Version: "1.18.3", FlavorVersion: 2, Flavor: manifest.IstioDistributionFlavorTetrateFIPS},
+					{Version: "1.18.1", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrateFIPS},
+				},
+			},
+			exp: &manifest.IstioDistribution{Version: "1.18.3", FlavorVersion: 2, Flavor: manifest.IstioDistributionFlavorTetrateFIPS},
+		},
+		{
+			// range constraint
+			flag: &fetchFlags{version: ">=1.17.5 <1.19", flavorVersion: 0},
+			mf: &manifest.Manifest{
+				IstioDistributions: []*manifest.IstioDistribution{
+					{Version: "1.19.0", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.17.5", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+					{Version: "1.18.2", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+				},
+			},
+			exp: &manifest.IstioDistribution{Version: "1.18.2", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+		},
+		{
+			// no distribution satisfies the constraint
+			flag: &fetchFlags{version: "^1.20", flavorVersion: 0},
+			mf: &manifest.Manifest{
+				IstioDistributions: []*manifest.IstioDistribution{
+					{Version: "1.19.0", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+				},
+			},
+		},
 	} {
 		t.Run(fmt.Sprintf("%d-th case", i), func(t *testing.T) {
 			actual, err := fetchParams(c.flag, c.mf)
@@ -148,3 +228,65 @@ func Test_fetchParams(t *testing.T) {
 
 	}
 }
+
+func Test_fetchTargets(t *testing.T) {
+	mf := &manifest.Manifest{
+		IstioDistributions: []*manifest.IstioDistribution{
+			{Version: "1.10.3", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+			{Version: "1.9.5", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorIstio},
+		},
+	}
+
+	t.Run("no names", func(t *testing.T) {
+		actual, err := fetchTargets(&fetchFlags{flavorVersion: -1}, mf)
+		require.NoError(t, err)
+		require.Equal(t, []*manifest.IstioDistribution{mf.IstioDistributions[0]}, actual)
+	})
+
+	t.Run("names and file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "distributions.txt")
+		require.NoError(t, os.WriteFile(file, []byte(`# preinstalled in the CI image
+1.9.5-istio-v0
+
+1.8.3-tetratefips-v1
+  1.10.3-tetrate-v0
+`), 0644))
+
+		actual, err := fetchTargets(&fetchFlags{names: []string{"1.10.3-tetrate-v0"}, file: file}, mf)
+		require.NoError(t, err)
+		require.Equal(t, []*manifest.IstioDistribution{
+			{Version: "1.10.3", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorTetrate},
+			{Version: "1.9.5", FlavorVersion: 0, Flavor: manifest.IstioDistributionFlavorIstio},
+			{Version: "1.8.3", FlavorVersion: 1, Flavor: manifest.IstioDistributionFlavorTetrateFIPS},
+		}, actual)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		_, err := fetchTargets(&fetchFlags{names: []string{"1.10.3-tetrate-v0", "invalid"}}, mf)
+		require.Error(t, err)
+	})
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := fetchTargets(&fetchFlags{file: filepath.Join(t.TempDir(), "not-exist")}, mf)
+		require.Error(t, err)
+	})
+}
+
+func Test_fetchCheckPlatform(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		flags := &fetchFlags{}
+		require.NoError(t, fetchCheckPlatform(flags))
+		require.Equal(t, runtime.GOOS, flags.goos)
+		require.Equal(t, runtime.GOARCH, flags.goarch)
+	})
+
+	t.Run("other platform", func(t *testing.T) {
+		flags := &fetchFlags{goos: "windows", goarch: "arm64"}
+		require.Error(t, fetchCheckPlatform(flags))
+
+		flags.outputDir = "bundle"
+		require.NoError(t, fetchCheckPlatform(flags))
+		require.Equal(t, "windows", flags.goos)
+		require.Equal(t, "arm64", flags.goarch)
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func newImportCmd(homedir string) *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "import <tarball>",
+		Short: "Import istioctl from a local Istio release archive",
+		Long: `Import istioctl from a local Istio release archive
+
+This is for the environments where the download host is not reachable. The archive must be the release tarball
+of the given distribution, and is installed as if it were fetched. The checksum of the archive is verified
+when the manifest publishes it, and the import fails if the manifest cannot be fetched or verified.
+Only with --offline and no cached manifest, the archive is imported without the verification.`,
+		Example: `# Import the release archive copied from another machine
+$ getmesh import istio-1.18.2-tetrate-v0-linux-amd64.tar.gz --name 1.18.2-tetrate-v0`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(name) == 0 {
+				return fmt.Errorf("--name must be given, e.g. 1.18.2-tetrate-v0")
+			}
+
+			d, err := getmesh.ParseDistributionName(name)
+			if err != nil {
+				return err
+			}
+
+			ms, err := importFetchManifest()
+			if err != nil {
+				return err
+			}
+
+			if err := istioctl.Import(homedir, args[0], d, ms); err != nil {
+				return err
+			}
+
+			if active := getmesh.GetActiveConfig().IstioDistribution; active == nil || !active.Equal(d) {
+				logger.Infof("Run `getmesh switch --name %s` to use it\n", d.String())
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&name, "name", "", "", "Name of the distribution in the archive, e.g. 1.18.2-tetrate-v0")
+	return cmd
+}
+
+// the manifest failing to be fetched or verified must not let the unverified archive in,
+// so the import proceeds without the manifest only when there is none, i.e. offline without the cache
+func importFetchManifest() (*manifest.Manifest, error) {
+	ms, err := manifest.FetchManifest()
+	if errors.Is(err, manifest.ErrNoCachedManifest) {
+		logger.Warnf("importing without the manifest: %v. The checksum of the archive is not verified\n", err)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error fetching the manifest to verify the archive: %w. "+
+			"Run with --offline to import it without the manifest when none is cached", err)
+	}
+	return ms, nil
+}
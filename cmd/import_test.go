@@ -0,0 +1,55 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func Test_importFetchManifest(t *testing.T) {
+	manifest.GlobalManifestURLMux.Lock()
+	defer manifest.GlobalManifestURLMux.Unlock()
+
+	t.Run("unavailable", func(t *testing.T) {
+		t.Setenv("GETMESH_TEST_MANIFEST_PATH", filepath.Join(t.TempDir(), "manifest.json"))
+		_, err := importFetchManifest()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "error fetching the manifest to verify the archive")
+	})
+
+	t.Run("offline without cache", func(t *testing.T) {
+		t.Setenv("GETMESH_TEST_MANIFEST_PATH", "")
+		manifest.SetOffline(true)
+		defer manifest.SetOffline(false)
+		manifest.SetCache(t.TempDir(), time.Hour)
+		defer manifest.SetCache("", manifest.DefaultCacheTTL)
+
+		var ms *manifest.Manifest
+		buf := logger.ExecuteWithLock(func() {
+			var err error
+			ms, err = importFetchManifest()
+			require.NoError(t, err)
+		})
+		require.Nil(t, ms)
+		require.Contains(t, buf.String(), "[WARNING] importing without the manifest: no cached manifest available in offline mode")
+	})
+}
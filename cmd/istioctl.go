@@ -38,10 +38,25 @@ func newIstioCmd(homedir string) *cobra.Command {
 	return &cobra.Command{
 		Use:   "istioctl <args...>",
 		Short: "Execute istioctl with given arguments",
-		Long:  `Execute istioctl with given arguments where the version of istioctl is set by "getsitio fetch or switch"`,
+		Long: `Execute istioctl with given arguments where the version of istioctl is set by "getsitio fetch or switch"
+
+The output of istioctl is passed through as is, and getmesh exits with the same exit code as istioctl.
+SIGINT, SIGTERM and SIGHUP received by getmesh are forwarded to istioctl. Only when getmesh runs in the foreground of
+the terminal, SIGINT is not forwarded since Ctrl-C reaches istioctl directly from the terminal.
+
+The version can be pinned per directory tree by the .getmesh-version file containing a distribution name,
+e.g. "1.18.2-tetrate-v0", which is looked up from the working directory upwards and takes precedence over "getmesh switch".
+The pinned version is fetched automatically if GETMESH_AUTO_FETCH=true or "auto_fetch" is true in config.json.
+
+To run the specific fetched version only for one command, give it by --use before the istioctl arguments,
+or by GETMESH_ISTIO_VERSION environment variable. These take precedence over .getmesh-version and never change the active version.`,
 		Example: `# install Istio with the default profile
 getmesh istioctl install --set profile=default
 
+# analyze with istioctl 1.17.3-tetrate-v0 without switching the active version
+getmesh istioctl --use 1.17.3-tetrate-v0 -- analyze
+GETMESH_ISTIO_VERSION=1.17.3-tetrate-v0 getmesh istioctl analyze
+
 # check versions of Istio data plane, control plane, and istioctl
 getmesh istioctl version`,
 		PreRunE: func(_ *cobra.Command, args []string) error {
@@ -49,7 +64,11 @@ getmesh istioctl version`,
 			if cur == nil {
 				return errors.New("please fetch Istioctl by `getmesh fetch` beforehand")
 			}
-			var err error
+			// --use is already applied in the root command
+			_, args, err := istioctlParseUseFlag(args)
+			if err != nil {
+				return err
+			}
 			processedArgs, err = istioctlArgChecks(args, cur, getmesh.GetActiveConfig().DefaultHub)
 			if err != nil {
 				return err
@@ -59,19 +78,18 @@ getmesh istioctl version`,
 		},
 
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			if err := istioctl.Exec(homedir, processedArgs); err != nil {
-				return fmt.Errorf("error executing istioctl: %v", err)
-			}
-			return nil
+			return istioctlExecError(istioctl.Exec(homedir, processedArgs))
 		},
 
 		// verify on whether istiod and CRDs are installed correctly
 		PostRunE: func(_ *cobra.Command, args []string) error {
+			_, args, err := istioctlParseUseFlag(args)
+			if err != nil {
+				return err
+			}
 			args = istioctlParseVerifyInstallArgs(args)
 			if len(args) > 0 {
-				if err := istioctl.Exec(homedir, args); err != nil {
-					return fmt.Errorf("error executing istioctl: %v", err)
-				}
+				return istioctlExecError(istioctl.Exec(homedir, args))
 			}
 			return nil
 		},
@@ -80,6 +98,41 @@ getmesh istioctl version`,
 	}
 }
 
+// istioctlExecError passes the exit code of istioctl through as is, since istioctl has already reported the error by itself
+func istioctlExecError(err error) error {
+	var exitErr *istioctl.ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		return err
+	}
+	return fmt.Errorf("error executing istioctl: %v", err)
+}
+
+// istioctlParseUseFlag extracts the distribution given by "--use <name>" or "--use=<name>" at the beginning of args,
+// and returns the rest of args without the following "--" if any
+func istioctlParseUseFlag(args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", args, nil
+	}
+
+	var use string
+	switch {
+	case args[0] == "--use":
+		if len(args) < 2 {
+			return "", nil, errors.New("--use requires a distribution name, e.g. --use 1.17.3-tetrate-v0")
+		}
+		use, args = args[1], args[2:]
+	case strings.HasPrefix(args[0], "--use="):
+		use, args = strings.TrimPrefix(args[0], "--use="), args[1:]
+	default:
+		return "", args, nil
+	}
+
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	return use, args, nil
+}
+
 func istioctlArgChecks(args []string, currentDistro *manifest.IstioDistribution, defaultHub string) ([]string, error) {
 	// Sanitize args.
 	out := istioctlPreProcessArgs(args)
@@ -93,35 +146,6 @@ func istioctlArgChecks(args []string, currentDistro *manifest.IstioDistribution,
 	for _, a := range out {
 		if a == "install" {
 			hasInstallCMD = true
-			ms, err := manifest.FetchManifest()
-			if err != nil {
-				return nil, err
-			}
-
-			if err := manifestchecker.Check(ms); err != nil {
-				return nil, err
-			}
-
-			ok, err := currentDistro.ExistInManifest(ms)
-			if err != nil {
-				return nil, err
-			} else if !ok {
-				logger.Warnf("Your active istioctl of version %s is deprecated. "+
-					"We recommend you use the supported distribution listed in \"getmesh list\" command. \n", currentDistro.String())
-				p := promptui.Prompt{
-					Label:     "Proceed",
-					IsConfirm: true,
-				}
-				if _, err := p.Run(); err != nil {
-					// error returned when it's not confirmed
-					return nil, err
-				}
-			}
-
-			err = istioctlPatchVersionCheck(currentDistro, ms)
-			if err != nil {
-				return nil, err
-			}
 		}
 
 		// Search "--set hub=..." args.
@@ -133,6 +157,12 @@ func istioctlArgChecks(args []string, currentDistro *manifest.IstioDistribution,
 		prev = a
 	}
 
+	if hasInstallCMD {
+		if err := istioctlInstallManifestChecks(currentDistro); err != nil {
+			return nil, err
+		}
+	}
+
 	// Insert the default hub set by "getmesh default-hub --set".
 	if hasInstallCMD && !hasHubParameter {
 		if defaultHub != "" {
@@ -142,6 +172,41 @@ func istioctlArgChecks(args []string, currentDistro *manifest.IstioDistribution,
 	return out, nil
 }
 
+// check on whether the current version is listed in the manifest and is the latest patch
+func istioctlInstallManifestChecks(currentDistro *manifest.IstioDistribution) error {
+	if currentDistro.IsCustomFlavor() {
+		// the linked builds are never listed in the manifest
+		return nil
+	}
+
+	ms, err := manifest.FetchManifest()
+	if err != nil {
+		return err
+	}
+
+	if err := manifestchecker.Check(ms); err != nil {
+		return err
+	}
+
+	ok, err := currentDistro.ExistInManifest(ms)
+	if err != nil {
+		return err
+	} else if !ok {
+		logger.Warnf("Your active istioctl of version %s is deprecated. "+
+			"We recommend you use the supported distribution listed in \"getmesh list\" command. \n", currentDistro.String())
+		p := promptui.Prompt{
+			Label:     "Proceed",
+			IsConfirm: true,
+		}
+		if _, err := p.Run(); err != nil {
+			// error returned when it's not confirmed
+			return err
+		}
+	}
+
+	return istioctlPatchVersionCheck(currentDistro, ms)
+}
+
 // check on whether the current version is the latest patch given current group version
 func istioctlPatchVersionCheck(current *manifest.IstioDistribution, ms *manifest.Manifest) error {
 	latestPatch, _, err := manifest.GetLatestDistribution(current, ms)
@@ -189,14 +254,15 @@ func istioctlParsePreCheckArgs(args []string) []string {
 }
 
 func istioK8scompatibilityCheck(homedir string, args []string) error {
-	// if current istioctl does not support preCheck in either stable or experimental version
-	// getmesh will bypass preCheck
-	if !istioctlHasPreCheckCommand(homedir) {
+	// precheck is only for install, and the other commands must run istioctl just once
+	args = istioctlParsePreCheckArgs(args)
+	if len(args) == 0 {
 		return nil
 	}
 
-	args = istioctlParsePreCheckArgs(args)
-	if len(args) == 0 {
+	// if current istioctl does not support preCheck in either stable or experimental version
+	// getmesh will bypass preCheck
+	if !istioctlHasPreCheckCommand(homedir) {
 		return nil
 	}
 
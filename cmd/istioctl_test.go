@@ -16,16 +16,40 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/tetratelabs/getmesh/internal/istioctl"
 	"github.com/tetratelabs/getmesh/internal/manifest"
 	"github.com/tetratelabs/getmesh/internal/test"
 	"github.com/tetratelabs/getmesh/internal/util"
 	"github.com/tetratelabs/getmesh/internal/util/logger"
 )
 
+func TestIstioctl_istioctlParseUseFlag(t *testing.T) {
+	for _, c := range []struct {
+		args    []string
+		expUse  string
+		expArgs []string
+	}{
+		{args: []string{"analyze"}, expArgs: []string{"analyze"}},
+		{args: []string{"--use", "1.17.3-tetrate-v0", "--", "analyze"}, expUse: "1.17.3-tetrate-v0", expArgs: []string{"analyze"}},
+		{args: []string{"--use=1.17.3-tetrate-v0", "analyze", "-A"}, expUse: "1.17.3-tetrate-v0", expArgs: []string{"analyze", "-A"}},
+		{args: []string{"analyze", "--use", "1.17.3-tetrate-v0"}, expArgs: []string{"analyze", "--use", "1.17.3-tetrate-v0"}},
+		{args: []string{"--use", "1.17.3-tetrate-v0"}, expUse: "1.17.3-tetrate-v0", expArgs: []string{}},
+	} {
+		use, args, err := istioctlParseUseFlag(c.args)
+		require.NoError(t, err)
+		require.Equal(t, c.expUse, use)
+		require.Equal(t, c.expArgs, args)
+	}
+
+	_, _, err := istioctlParseUseFlag([]string{"--use"})
+	require.Error(t, err)
+}
+
 func TestIstioctl_istioctlArgChecks(t *testing.T) {
 	manifest.GlobalManifestURLMux.Lock()
 	defer manifest.GlobalManifestURLMux.Unlock()
@@ -94,6 +118,20 @@ func TestIstioctl_istioctlArgChecks(t *testing.T) {
 		require.Contains(t, buf.String(), "Your active istioctl of version 1.7.4-tetratefips-v0 is deprecated.")
 		t.Log(buf.String())
 	})
+
+	t.Run("linked", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			// no confirmation is prompted for the custom flavor
+			out, err := istioctlArgChecks([]string{"install"}, &manifest.IstioDistribution{
+				Version:       "1.18.2",
+				Flavor:        "acme",
+				FlavorVersion: 3,
+			}, "")
+			require.NoError(t, err)
+			require.Equal(t, []string{"install"}, out)
+		})
+		require.Empty(t, buf.String())
+	})
 }
 
 func TestIstioctl_istioctlParsePreCheckArgs(t *testing.T) {
@@ -371,3 +409,13 @@ func TestIstioctl_istioctlPreProcessArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestIstioctl_istioctlExecError(t *testing.T) {
+	require.NoError(t, istioctlExecError(nil))
+
+	// the exit code is passed through as is
+	exitErr := &istioctl.ExitError{Code: 3}
+	require.Equal(t, exitErr, istioctlExecError(exitErr))
+
+	require.EqualError(t, istioctlExecError(errors.New("not fetched")), "error executing istioctl: not fetched")
+}
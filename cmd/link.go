@@ -0,0 +1,68 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func newLinkCmd(homedir string) *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "link <istioctl>",
+		Short: "Register a locally built istioctl under a custom flavor",
+		Long: `Register a locally built istioctl under a custom flavor
+
+The istioctl is linked, not copied, so rebuilding it takes effect without linking again.
+The linked distribution can be switched to and pruned like the fetched ones. It is shown as "unmanaged",
+and is not checked against the manifest. Linking the same name again replaces the link.`,
+		Example: `# Register the patched istioctl as the flavor "acme"
+$ getmesh link --name 1.18.2-acme-v3 /path/to/istioctl
+
+# Use it
+$ getmesh switch --name 1.18.2-acme-v3`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(name) == 0 {
+				return fmt.Errorf("--name must be given, e.g. 1.18.2-acme-v0")
+			}
+
+			d, err := getmesh.ParseDistributionName(name)
+			if err != nil {
+				return err
+			}
+
+			unlock, err := getmesh.LockHome(homedir)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			if err := istioctl.Link(homedir, args[0], d); err != nil {
+				return err
+			}
+			logger.Infof("%s linked to %s\n", d.String(), args[0])
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&name, "name", "", "", "Name of the distribution with a custom flavor, e.g. 1.18.2-acme-v0")
+	return cmd
+}
@@ -16,19 +16,27 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
 	"github.com/tetratelabs/getmesh/internal/manifest"
 	"github.com/tetratelabs/getmesh/internal/manifestchecker"
 )
 
-func newListCmd() *cobra.Command {
-	return &cobra.Command{
+func newListCmd(homedir string) *cobra.Command {
+	var (
+		output string
+		filter manifest.ListFilter
+	)
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List available Istio distributions built by Tetrate",
-		Long:  `List available Istio distributions built by Tetrate`,
+		Long: `List available Istio distributions built by Tetrate
+
+The distributions can be narrowed down by the flags, and the flags are combined with AND.`,
 		Example: `$ getmesh list
 
 ISTIO VERSION	FLAVOR 	FLAVOR VERSION	 K8S VERSIONS
@@ -59,6 +67,23 @@ exactly the same source code of the corresponding upstream Istio version.
 
 [K8S VERSIONS]
 Supported k8s versions for the distribution
+
+[END OF LIFE]
+The date when the minor version of the distribution reaches the end of life
+
+"-o wide" adds the following columns:
+
+[SECURITY PATCH]
+Whether the distribution contains security fixes
+
+[INSTALLED]
+Whether the distribution has been fetched locally
+
+# List the supported tetrate flavor distributions of Istio 1.18 for k8s 1.26
+$ getmesh list --flavor tetrate --minor 1.18 --k8s-version 1.26 --supported-only
+
+# List the security patches in JSON for scripts
+$ getmesh list --security-patches-only -o json
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ms, err := manifest.FetchManifest()
@@ -66,14 +91,39 @@ Supported k8s versions for the distribution
 				return fmt.Errorf("error fetching manifest: %v", err)
 			}
 
-			if err := manifestchecker.Check(ms); err != nil {
+			// keep the stdout parsable in the json and yaml outputs
+			if output == "table" || output == "wide" {
+				if err := manifestchecker.Check(ms); err != nil {
+					return err
+				}
+			}
+
+			fetched, err := istioctl.GetInstalledDistributions(homedir, nil)
+			if err != nil {
 				return err
 			}
+			installed := make([]*manifest.IstioDistribution, len(fetched))
+			for i, d := range fetched {
+				installed[i] = &manifest.IstioDistribution{Version: d.Version, Flavor: d.Flavor, FlavorVersion: d.FlavorVersion}
+			}
 
-			if err := manifest.PrintManifest(ms, getmesh.GetActiveConfig().IstioDistribution); err != nil {
-				return fmt.Errorf("error executing istioctl: %v", err)
+			filter.Now = time.Now()
+			ds, err := manifest.ListDistributions(ms, getmesh.GetActiveConfig().IstioDistribution, installed, &filter)
+			if err != nil {
+				return err
 			}
-			return nil
+			return manifest.PrintDistributions(ds, output)
 		},
 	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+	flags.StringVarP(&output, "output", "o", "table", "Output format, one of table, wide, json or yaml")
+	flags.StringVarP(&filter.Flavor, "flavor", "", "", "Only list the distributions of the flavor, e.g. tetrate, tetratefips or istio")
+	flags.StringVarP(&filter.Minor, "minor", "", "", "Only list the distributions of the Istio minor version, e.g. 1.18")
+	flags.StringVarP(&filter.K8SVersion, "k8s-version", "", "", "Only list the distributions supporting the k8s version, e.g. 1.26")
+	flags.BoolVarP(&filter.SecurityPatchesOnly, "security-patches-only", "", false, "Only list the security patches")
+	flags.BoolVarP(&filter.SupportedOnly, "supported-only", "", false, "Only list the distributions not past the end of life")
+	flags.BoolVarP(&filter.InstalledOnly, "installed", "", false, "Only list the distributions fetched locally")
+	return cmd
 }
@@ -16,6 +16,9 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -29,24 +32,81 @@ func newPruneCmd(homedir string) *cobra.Command {
 		flagVersion       string
 		flagFlavor        string
 		flagFlavorVersion int
+		flagOlderThan     string
+		flagDryRun        bool
+		policy            istioctl.PrunePolicy
 	)
 
 	cmd := &cobra.Command{
 		Use:   "prune",
 		Short: "Remove specific istioctl installed, or all, except the active one",
-		Long:  "Remove specific istioctl installed, or all, except the active one",
+		Long: `Remove specific istioctl installed, or all, except the active one
+
+The policy flags remove the distributions matching any of them instead, and can be combined.
+The active one is never removed.`,
 		Example: `# remove all the installed
 $ getmesh prune
 
 # remove the specific distribution
 $ getmesh prune --version 1.7.4 --flavor tetrate --flavor-version 0
+
+# keep only the newest two patches of each minor version and flavor
+$ getmesh prune --keep-latest-patches 2
+
+# remove the end of life distributions and the ones no longer in the manifest
+$ getmesh prune --end-of-life --not-in-manifest
+
+# show what would be removed without removing, for the ones fetched more than 30 days ago
+$ getmesh prune --older-than 30d --dry-run
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			target, err := pruneCheckFlags(flagVersion, flagFlavor, flagFlavorVersion)
 			if err != nil {
 				return err
 			}
-			return istioctl.Remove(homedir, target, getmesh.GetActiveConfig().IstioDistribution)
+
+			if len(flagOlderThan) != 0 {
+				if policy.OlderThan, err = parseAge(flagOlderThan); err != nil {
+					return err
+				}
+			}
+
+			if target != nil && policy.Enabled() {
+				return fmt.Errorf("the policy flags cannot be used with a specific version")
+			}
+
+			var ms *manifest.Manifest
+			if policy.RequiresManifest() {
+				if ms, err = manifest.FetchManifest(); err != nil {
+					return fmt.Errorf("error fetching manifest: %v", err)
+				}
+			}
+
+			unlock, err := getmesh.LockHome(homedir)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			active := getmesh.GetActiveConfig().IstioDistribution
+			if !flagDryRun && !policy.Enabled() {
+				return istioctl.Remove(homedir, target, active)
+			}
+
+			ds, err := istioctl.GetInstalledDistributions(homedir, ms)
+			if err != nil {
+				return err
+			}
+
+			if target != nil {
+				ds = pruneFilterTarget(ds, target)
+			}
+
+			targets, err := istioctl.SelectPruneTargets(ds, &policy, time.Now())
+			if err != nil {
+				return err
+			}
+			return istioctl.Prune(homedir, targets, flagDryRun)
 		},
 	}
 
@@ -55,6 +115,11 @@ $ getmesh prune --version 1.7.4 --flavor tetrate --flavor-version 0
 	flags.StringVarP(&flagVersion, "version", "", "", "Version of istioctl e.g. 1.7.4")
 	flags.StringVarP(&flagFlavor, "flavor", "", "", "Flavor of istioctl, e.g. \"tetrate\" or \"tetratefips\" or \"istio\"")
 	flags.IntVarP(&flagFlavorVersion, "flavor-version", "", -1, "Version of the flavor, e.g. 1")
+	flags.IntVarP(&policy.KeepLatestPatches, "keep-latest-patches", "", 0, "Keep the newest N distributions of each minor version and flavor, and remove the others")
+	flags.BoolVarP(&policy.EndOfLife, "end-of-life", "", false, "Remove the distributions whose minor version is past the end of life in the manifest")
+	flags.BoolVarP(&policy.NotInManifest, "not-in-manifest", "", false, "Remove the distributions which are not in the manifest")
+	flags.StringVarP(&flagOlderThan, "older-than", "", "", "Remove the distributions fetched before the age, e.g. 30d or 12h")
+	flags.BoolVarP(&flagDryRun, "dry-run", "", false, "Print the distributions to be removed and the disk space to be freed without removing")
 	return cmd
 }
 
@@ -73,3 +138,29 @@ func pruneCheckFlags(flagVersion string, flagFlavor string, flagFlavorVersion in
 	}
 	return target, nil
 }
+
+func pruneFilterTarget(ds []*istioctl.InstalledDistribution, target *manifest.IstioDistribution) []*istioctl.InstalledDistribution {
+	for _, d := range ds {
+		if d.Name == target.String() {
+			return []*istioctl.InstalledDistribution{d}
+		}
+	}
+	return nil
+}
+
+// parseAge parses the duration in time.ParseDuration format, additionally accepting days like "30d"
+func parseAge(in string) (time.Duration, error) {
+	if days := strings.TrimSuffix(in, "d"); days != in {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %s: must be like 30d or 12h", in)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	ret, err := time.ParseDuration(in)
+	if err != nil || ret < 0 {
+		return 0, fmt.Errorf("invalid age %s: must be like 30d or 12h", in)
+	}
+	return ret, nil
+}
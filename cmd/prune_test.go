@@ -17,6 +17,7 @@ package cmd
 import (
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -53,3 +54,31 @@ func Test_pruneCheckFlags(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseAge(t *testing.T) {
+	for _, c := range []struct {
+		in     string
+		exp    time.Duration
+		expErr bool
+	}{
+		{in: "30d", exp: 30 * 24 * time.Hour},
+		{in: "0d", exp: 0},
+		{in: "12h", exp: 12 * time.Hour},
+		{in: "1h30m", exp: 90 * time.Minute},
+		{in: "d", expErr: true},
+		{in: "-1d", expErr: true},
+		{in: "-1h", expErr: true},
+		{in: "30", expErr: true},
+		{in: "1w", expErr: true},
+	} {
+		t.Run(c.in, func(t *testing.T) {
+			actual, err := parseAge(c.in)
+			if c.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, actual)
+		})
+	}
+}
@@ -15,18 +15,54 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/manifest"
 	"github.com/tetratelabs/getmesh/internal/util"
+	"github.com/tetratelabs/getmesh/internal/util/httpclient"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
 )
 
+const (
+	manifestURLsEnvName    = "GETMESH_MANIFEST_URLS"    // comma separated list of manifest URLs
+	manifestTimeoutEnvName = "GETMESH_MANIFEST_TIMEOUT" // e.g. "10s"
+	offlineEnvName         = "GETMESH_OFFLINE"          // "true" to use the cached manifest only
+	autoFetchEnvName       = "GETMESH_AUTO_FETCH"       // "true" to fetch the pinned istioctl if not fetched yet
+	istioVersionEnvName    = "GETMESH_ISTIO_VERSION"    // distribution name used in place of the active one, e.g. "1.17.3-tetrate-v0"
+
+	insecureSkipManifestVerifyEnvName = "GETMESH_INSECURE_SKIP_MANIFEST_VERIFY" // "true" to skip the manifest signature verification
+
+	httpProxyEnvName   = "GETMESH_HTTP_PROXY"   // e.g. "http://proxy.example.com:3128"
+	caBundleEnvName    = "GETMESH_CA_BUNDLE"    // path to the PEM encoded CA certificates
+	httpTimeoutEnvName = "GETMESH_HTTP_TIMEOUT" // e.g. "30s"
+	httpRetriesEnvName = "GETMESH_HTTP_RETRIES" // e.g. "3"
+)
+
+// the commands running the active istioctl, for which GETMESH_ISTIO_VERSION and .getmesh-version take effect
+var pinnedVersionCommands = map[string]struct{}{
+	"istioctl":          {},
+	"version":           {},
+	"check-upgrade":     {},
+	"config-validate":   {},
+	istioctlShimCmdName: {},
+}
+
 func Execute(version, homeDir string) {
 	cmd := NewRoot(version, homeDir)
 	if err := cmd.Execute(); err != nil {
+		var exitErr *istioctl.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		fmt.Fprintln(os.Stderr, err)
 		handleUnknowns(cmd, err)
 		os.Exit(1)
@@ -71,27 +107,284 @@ func getCommandCallPath(cmd *cobra.Command, calledArgs *[]*cobra.Command) {
 }
 
 func NewRoot(version, homeDir string) *cobra.Command {
+	var (
+		manifestURLs               []string
+		manifestTimeout            time.Duration
+		manifestCacheTTL           time.Duration
+		offline                    bool
+		insecureSkipManifestVerify bool
+	)
+
+	getmesh.SetVersion(version)
 	cmd := &cobra.Command{
 		SilenceUsage:      true,
 		SilenceErrors:     true,
 		Use:               "getmesh",
 		DisableAutoGenTag: true,
 		Short:             `getmesh is an integration and lifecycle management CLI tool that ensures the use of supported and trusted versions of Istio.`,
-		Long:              `getmesh is an integration and lifecycle management CLI tool that ensures the use of supported and trusted versions of Istio.`,
+		Long: `getmesh is an integration and lifecycle management CLI tool that ensures the use of supported and trusted versions of Istio.
+
+The manifest and istioctl are downloaded with the following settings, which can be set in config.json
+or by the environment variables taking precedence:
+- "http_proxy" or GETMESH_HTTP_PROXY: URL of the proxy used in place of HTTPS_PROXY and HTTP_PROXY
+- "ca_bundle" or GETMESH_CA_BUNDLE: path to the PEM encoded CA certificates trusted in addition to the system ones
+- "http_timeout" or GETMESH_HTTP_TIMEOUT: timeout for connecting and waiting for the response headers, 30s by default
+- "http_retries" or GETMESH_HTTP_RETRIES: number of retries with backoff on connection errors and 5xx responses, 3 by default`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// keep the stdout parsable in the json and yaml outputs
+			if f := cmd.Flags().Lookup("output"); f != nil && (f.Value.String() == "json" || f.Value.String() == "yaml") {
+				logger.SetDiagnosticWriter(cmd.ErrOrStderr())
+			}
+
+			conf := getmesh.GetActiveConfig()
+			hc, err := getHTTPClientConfig(&conf, version)
+			if err != nil {
+				return err
+			}
+			if err := httpclient.Configure(hc); err != nil {
+				return err
+			}
+
+			ss, err := getManifestSources(manifestURLs, manifestTimeout, conf.ManifestSources)
+			if err != nil {
+				return err
+			}
+			if err := manifest.SetSources(ss); err != nil {
+				return err
+			}
+
+			ttl, err := getManifestCacheTTL(cmd.Flags().Changed("manifest-cache-ttl"), manifestCacheTTL, conf.ManifestCacheTTL)
+			if err != nil {
+				return err
+			}
+			manifest.SetCache(homeDir, ttl)
+
+			if offline, err = getBoolFlagOrEnv(cmd.Flags().Changed("offline"), offline, offlineEnvName); err != nil {
+				return err
+			}
+			manifest.SetOffline(offline)
+
+			if err := manifest.SetPublicKeys(conf.ManifestPublicKeys); err != nil {
+				return err
+			}
+			manifest.SetRequireSignature(conf.RequireManifestSignature)
+			insecureSkipManifestVerify, err = getBoolFlagOrEnv(cmd.Flags().Changed("insecure-skip-manifest-verify"),
+				insecureSkipManifestVerify, insecureSkipManifestVerifyEnvName)
+			if err != nil {
+				return err
+			}
+			if insecureSkipManifestVerify {
+				logger.Warnf("the manifest signature verification is skipped\n")
+			}
+			manifest.SetInsecureSkipVerify(insecureSkipManifestVerify)
+
+			if _, ok := pinnedVersionCommands[cmd.Name()]; ok {
+				autoFetch, err := getBoolFlagOrEnv(false, conf.AutoFetch, autoFetchEnvName)
+				if err != nil {
+					return err
+				}
+
+				var use string
+				if cmd.Name() == "istioctl" {
+					// the flags of istioctl command are not parsed by cobra
+					if use, _, err = istioctlParseUseFlag(args); err != nil {
+						return err
+					}
+				}
+
+				wd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				return applyIstioVersionOverride(homeDir, wd, use, autoFetch)
+			}
+			return nil
+		},
 	}
 
 	cmd.AddCommand(newIstioCmd(homeDir))
-	cmd.AddCommand(newListCmd())
+	cmd.AddCommand(newListCmd(homeDir))
 	cmd.AddCommand(newSwitchCmd(homeDir))
 	cmd.AddCommand(newFetchCmd(homeDir))
+	cmd.AddCommand(newImportCmd(homeDir))
+	cmd.AddCommand(newLinkCmd(homeDir))
 	cmd.AddCommand(newVersionCmd(homeDir, version))
 	cmd.AddCommand(newCheckCmd(homeDir))
+	cmd.AddCommand(newUpgradePlanCmd(homeDir))
 	cmd.AddCommand(newShowCmd(homeDir))
 	cmd.AddCommand(newConfigValidateCmd(homeDir))
 	cmd.AddCommand(newGenCACmd())
 	cmd.AddCommand(newPruneCmd(homeDir))
+	cmd.AddCommand(newVerifyCmd(homeDir))
 	cmd.AddCommand(newSetDefaultHubCmd(homeDir))
+	cmd.AddCommand(newEnvCmd(homeDir))
+	cmd.AddCommand(newIstioctlShimCmd(homeDir))
 
 	cmd.PersistentFlags().StringVarP(&util.KubeConfig, "kubeconfig", "c", "", "Kubernetes configuration file")
+	cmd.PersistentFlags().StringSliceVar(&manifestURLs, "manifest-url", nil,
+		"URL of the manifest (https://, http:// or file://). Repeat to set fallback sources tried in order. "+
+			"Overrides "+manifestURLsEnvName+" and \"manifest_sources\" in config.json")
+	cmd.PersistentFlags().DurationVar(&manifestTimeout, "manifest-timeout", 0,
+		"Timeout for fetching the manifest from each source, e.g. 10s. Overrides "+manifestTimeoutEnvName)
+	cmd.PersistentFlags().DurationVar(&manifestCacheTTL, "manifest-cache-ttl", manifest.DefaultCacheTTL,
+		"Duration in which the cached manifest is used without revalidation. Overrides \"manifest_cache_ttl\" in config.json")
+	cmd.PersistentFlags().BoolVar(&offline, "offline", false,
+		"Use the cached manifest without accessing the network. Can also be set by "+offlineEnvName+"=true")
+	cmd.PersistentFlags().BoolVar(&insecureSkipManifestVerify, "insecure-skip-manifest-verify", false,
+		"Skip verifying the manifest signature. Not recommended. Can also be set by "+insecureSkipManifestVerifyEnvName+"=true")
 	return cmd
 }
+
+// make the distribution given by --use, GETMESH_ISTIO_VERSION or .getmesh-version active in this process
+// in this order of precedence without changing config.json, and fetch it if allowed
+func applyIstioVersionOverride(homeDir, wd, use string, autoFetch bool) error {
+	var (
+		d      *manifest.IstioDistribution
+		source string
+		err    error
+	)
+	if len(use) != 0 {
+		source = "--use"
+		d, err = getmesh.ParseDistributionName(use)
+	} else if env := os.Getenv(istioVersionEnvName); len(env) != 0 {
+		source = istioVersionEnvName
+		d, err = getmesh.ParseDistributionName(env)
+	} else {
+		d, source, err = getmesh.FindPinnedVersion(wd)
+	}
+	if err != nil {
+		if len(source) != 0 {
+			return fmt.Errorf("invalid %s: %v", source, err)
+		}
+		return err
+	} else if d == nil {
+		return nil
+	}
+	getmesh.SetIstioVersionOverride(d, source)
+
+	if _, err := os.Stat(istioctl.GetIstioctlPath(homeDir, d)); err == nil {
+		return nil
+	}
+
+	if !autoFetch {
+		logger.Warnf("%s set by %s is not fetched yet. Please run `getmesh fetch --name %s`, "+
+			"or set %s=true to fetch it automatically\n", d.String(), source, d.String(), autoFetchEnvName)
+		return nil
+	}
+
+	logger.Infof("fetching %s set by %s\n", d.String(), source)
+	ms, err := manifest.FetchManifest()
+	if err != nil {
+		return fmt.Errorf("error fetching manifest: %v", err)
+	}
+
+	return istioctl.Fetch(homeDir, d, ms)
+}
+
+// the flag takes precedence over the environment variable
+func getBoolFlagOrEnv(flagChanged, flagValue bool, envName string) (bool, error) {
+	v := os.Getenv(envName)
+	if flagChanged || len(v) == 0 {
+		return flagValue, nil
+	}
+
+	ret, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %v", envName, err)
+	}
+	return ret, nil
+}
+
+// the flag takes precedence over config.json
+func getManifestCacheTTL(flagChanged bool, flagTTL time.Duration, configured string) (time.Duration, error) {
+	if flagChanged || len(configured) == 0 {
+		return flagTTL, nil
+	}
+
+	ttl, err := time.ParseDuration(configured)
+	if err != nil {
+		return 0, fmt.Errorf("invalid manifest_cache_ttl %s in config.json: %v", configured, err)
+	}
+	return ttl, nil
+}
+
+// resolve the manifest sources in the order of precedence: flags, environment variables and config.json
+func getManifestSources(flagURLs []string, flagTimeout time.Duration, configured []manifest.Source) ([]manifest.Source, error) {
+	urls := flagURLs
+	if len(urls) == 0 {
+		if env := os.Getenv(manifestURLsEnvName); len(env) != 0 {
+			urls = strings.Split(env, ",")
+		}
+	}
+
+	timeout := os.Getenv(manifestTimeoutEnvName)
+	if flagTimeout > 0 {
+		timeout = flagTimeout.String()
+	} else if len(timeout) != 0 {
+		if _, err := time.ParseDuration(timeout); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", manifestTimeoutEnvName, err)
+		}
+	}
+
+	var ret []manifest.Source
+	if len(urls) != 0 {
+		for _, u := range urls {
+			if u = strings.TrimSpace(u); len(u) != 0 {
+				ret = append(ret, manifest.Source{URL: u, Timeout: timeout})
+			}
+		}
+		return ret, nil
+	}
+
+	for _, s := range configured {
+		if len(timeout) != 0 {
+			s.Timeout = timeout
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// resolve the HTTP client settings, where the environment variables take precedence over config.json
+func getHTTPClientConfig(conf *getmesh.Config, version string) (httpclient.Config, error) {
+	ret := httpclient.Config{
+		Proxy:    conf.HTTPProxy,
+		CABundle: conf.CABundle,
+		Timeout:  httpclient.DefaultTimeout,
+		Retries:  httpclient.DefaultRetries,
+		Version:  version,
+	}
+	if v := os.Getenv(httpProxyEnvName); len(v) != 0 {
+		ret.Proxy = v
+	}
+	if v := os.Getenv(caBundleEnvName); len(v) != 0 {
+		ret.CABundle = v
+	}
+
+	if len(conf.HTTPTimeout) != 0 {
+		d, err := time.ParseDuration(conf.HTTPTimeout)
+		if err != nil {
+			return ret, fmt.Errorf("invalid http_timeout %s in config.json: %v", conf.HTTPTimeout, err)
+		}
+		ret.Timeout = d
+	}
+	if v := os.Getenv(httpTimeoutEnvName); len(v) != 0 {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return ret, fmt.Errorf("invalid %s: %v", httpTimeoutEnvName, err)
+		}
+		ret.Timeout = d
+	}
+
+	if conf.HTTPRetries != nil {
+		ret.Retries = *conf.HTTPRetries
+	}
+	if v := os.Getenv(httpRetriesEnvName); len(v) != 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return ret, fmt.Errorf("invalid %s: %v", httpRetriesEnvName, err)
+		}
+		ret.Retries = n
+	}
+	return ret, nil
+}
@@ -0,0 +1,295 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/httpclient"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func Test_getManifestSources(t *testing.T) {
+	configured := []manifest.Source{
+		{URL: "https://mirror.example.com/manifest.json", Timeout: "5s"},
+		{URL: "file:///opt/getmesh/manifest.json"},
+	}
+
+	t.Run("config", func(t *testing.T) {
+		actual, err := getManifestSources(nil, 0, configured)
+		require.NoError(t, err)
+		require.Equal(t, configured, actual)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		actual, err := getManifestSources(nil, 0, nil)
+		require.NoError(t, err)
+		require.Empty(t, actual)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv(manifestURLsEnvName, "https://a.example.com/manifest.json, https://b.example.com/manifest.json")
+		t.Setenv(manifestTimeoutEnvName, "3s")
+		actual, err := getManifestSources(nil, 0, configured)
+		require.NoError(t, err)
+		require.Equal(t, []manifest.Source{
+			{URL: "https://a.example.com/manifest.json", Timeout: "3s"},
+			{URL: "https://b.example.com/manifest.json", Timeout: "3s"},
+		}, actual)
+	})
+
+	t.Run("env timeout for configured", func(t *testing.T) {
+		t.Setenv(manifestTimeoutEnvName, "3s")
+		actual, err := getManifestSources(nil, 0, configured)
+		require.NoError(t, err)
+		require.Equal(t, []manifest.Source{
+			{URL: "https://mirror.example.com/manifest.json", Timeout: "3s"},
+			{URL: "file:///opt/getmesh/manifest.json", Timeout: "3s"},
+		}, actual)
+	})
+
+	t.Run("invalid env timeout", func(t *testing.T) {
+		t.Setenv(manifestTimeoutEnvName, "three")
+		_, err := getManifestSources(nil, 0, configured)
+		require.Error(t, err)
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		t.Setenv(manifestURLsEnvName, "https://a.example.com/manifest.json")
+		actual, err := getManifestSources([]string{"http://flag.example.com/manifest.json"}, time.Second, configured)
+		require.NoError(t, err)
+		require.Equal(t, []manifest.Source{
+			{URL: "http://flag.example.com/manifest.json", Timeout: "1s"},
+		}, actual)
+	})
+}
+
+func Test_getManifestCacheTTL(t *testing.T) {
+	actual, err := getManifestCacheTTL(false, manifest.DefaultCacheTTL, "")
+	require.NoError(t, err)
+	require.Equal(t, manifest.DefaultCacheTTL, actual)
+
+	actual, err = getManifestCacheTTL(false, manifest.DefaultCacheTTL, "10m")
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Minute, actual)
+
+	actual, err = getManifestCacheTTL(true, time.Minute, "10m")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, actual)
+
+	_, err = getManifestCacheTTL(false, manifest.DefaultCacheTTL, "ten minutes")
+	require.Error(t, err)
+}
+
+func Test_getHTTPClientConfig(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		actual, err := getHTTPClientConfig(&getmesh.Config{}, "1.1.5")
+		require.NoError(t, err)
+		require.Equal(t, httpclient.Config{
+			Timeout: httpclient.DefaultTimeout,
+			Retries: httpclient.DefaultRetries,
+			Version: "1.1.5",
+		}, actual)
+	})
+
+	retries := 0
+	conf := &getmesh.Config{
+		HTTPProxy:   "http://proxy.example.com:3128",
+		CABundle:    "/etc/ssl/corp.pem",
+		HTTPTimeout: "10s",
+		HTTPRetries: &retries,
+	}
+	t.Run("config", func(t *testing.T) {
+		actual, err := getHTTPClientConfig(conf, "1.1.5")
+		require.NoError(t, err)
+		require.Equal(t, httpclient.Config{
+			Proxy:    "http://proxy.example.com:3128",
+			CABundle: "/etc/ssl/corp.pem",
+			Timeout:  10 * time.Second,
+			Retries:  0,
+			Version:  "1.1.5",
+		}, actual)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv(httpProxyEnvName, "http://other.example.com:3128")
+		t.Setenv(caBundleEnvName, "/tmp/ca.pem")
+		t.Setenv(httpTimeoutEnvName, "1m")
+		t.Setenv(httpRetriesEnvName, "5")
+		actual, err := getHTTPClientConfig(conf, "1.1.5")
+		require.NoError(t, err)
+		require.Equal(t, httpclient.Config{
+			Proxy:    "http://other.example.com:3128",
+			CABundle: "/tmp/ca.pem",
+			Timeout:  time.Minute,
+			Retries:  5,
+			Version:  "1.1.5",
+		}, actual)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := getHTTPClientConfig(&getmesh.Config{HTTPTimeout: "ten seconds"}, "")
+		require.Error(t, err)
+
+		t.Setenv(httpRetriesEnvName, "three")
+		_, err = getHTTPClientConfig(&getmesh.Config{}, "")
+		require.Error(t, err)
+	})
+}
+
+func Test_applyIstioVersionOverride(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+	defer getmesh.SetIstioVersionOverride(nil, "")
+
+	pinned := &manifest.IstioDistribution{Version: "1.18.2", Flavor: manifest.IstioDistributionFlavorTetrate, FlavorVersion: 0}
+	wd := t.TempDir()
+	pin := filepath.Join(wd, getmesh.PinFileName)
+
+	t.Run("not pinned", func(t *testing.T) {
+		require.NoError(t, applyIstioVersionOverride(t.TempDir(), wd, "", false))
+		require.Empty(t, getmesh.GetIstioVersionOverrideSource())
+	})
+
+	require.NoError(t, os.WriteFile(pin, []byte(pinned.String()), 0644))
+
+	t.Run("fetched", func(t *testing.T) {
+		defer getmesh.SetIstioVersionOverride(nil, "")
+		home := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Dir(istioctl.GetIstioctlPath(home, pinned)), 0755))
+		require.NoError(t, os.WriteFile(istioctl.GetIstioctlPath(home, pinned), nil, 0755))
+
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, applyIstioVersionOverride(home, wd, "", false))
+		})
+		require.Empty(t, buf.String())
+		require.Equal(t, pinned, getmesh.GetActiveConfig().IstioDistribution)
+		require.Equal(t, pin, getmesh.GetIstioVersionOverrideSource())
+	})
+
+	t.Run("not fetched", func(t *testing.T) {
+		defer getmesh.SetIstioVersionOverride(nil, "")
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, applyIstioVersionOverride(t.TempDir(), wd, "", false))
+		})
+		require.Contains(t, buf.String(), "getmesh fetch --name 1.18.2-tetrate-v0")
+		require.Equal(t, pinned, getmesh.GetActiveConfig().IstioDistribution)
+	})
+
+	t.Run("auto fetch", func(t *testing.T) {
+		defer getmesh.SetIstioVersionOverride(nil, "")
+		manifest.GlobalManifestURLMux.Lock()
+		defer manifest.GlobalManifestURLMux.Unlock()
+
+		var requested string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = r.URL.Path
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		raw, err := json.Marshal(&manifest.Manifest{
+			IstioDistributions:  []*manifest.IstioDistribution{pinned},
+			ArtifactURLTemplate: ts.URL + "/{{.Distribution}}.tar.gz",
+		})
+		require.NoError(t, err)
+		manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+		require.NoError(t, os.WriteFile(manifestPath, raw, 0644))
+		t.Setenv("GETMESH_TEST_MANIFEST_PATH", manifestPath)
+
+		logger.ExecuteWithLock(func() {
+			err = applyIstioVersionOverride(t.TempDir(), wd, "", true)
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "404")
+		require.Equal(t, "/1.18.2-tetrate-v0.tar.gz", requested)
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		defer getmesh.SetIstioVersionOverride(nil, "")
+		home := t.TempDir()
+		for _, d := range []string{"1.17.3-tetrate-v0", "1.16.1-istio-v0", pinned.String()} {
+			dist, err := manifest.IstioDistributionFromString(d)
+			require.NoError(t, err)
+			require.NoError(t, os.MkdirAll(filepath.Dir(istioctl.GetIstioctlPath(home, dist)), 0755))
+			require.NoError(t, os.WriteFile(istioctl.GetIstioctlPath(home, dist), nil, 0755))
+		}
+
+		t.Setenv(istioVersionEnvName, "1.16.1-istio-v0")
+		require.NoError(t, applyIstioVersionOverride(home, wd, "1.17.3-tetrate-v0", false))
+		require.Equal(t, "1.17.3-tetrate-v0", getmesh.GetActiveConfig().IstioDistribution.String())
+		require.Equal(t, "--use", getmesh.GetIstioVersionOverrideSource())
+
+		require.NoError(t, applyIstioVersionOverride(home, wd, "", false))
+		require.Equal(t, "1.16.1-istio-v0", getmesh.GetActiveConfig().IstioDistribution.String())
+		require.Equal(t, istioVersionEnvName, getmesh.GetIstioVersionOverrideSource())
+
+		t.Setenv(istioVersionEnvName, "")
+		require.NoError(t, applyIstioVersionOverride(home, wd, "", false))
+		require.Equal(t, pinned, getmesh.GetActiveConfig().IstioDistribution)
+		require.Equal(t, pin, getmesh.GetIstioVersionOverrideSource())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		defer getmesh.SetIstioVersionOverride(nil, "")
+		err := applyIstioVersionOverride(t.TempDir(), wd, "1.17.3", false)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid --use")
+
+		t.Setenv(istioVersionEnvName, "invalid")
+		err = applyIstioVersionOverride(t.TempDir(), wd, "", false)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid "+istioVersionEnvName)
+	})
+}
+
+func TestNewRoot_structuredOutput(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	home := t.TempDir()
+	require.NoError(t, getmesh.SetIstioVersion(home, nil))
+	defer manifest.SetInsecureSkipVerify(false)
+	defer manifest.SetCache("", 0)
+	defer logger.SetDiagnosticWriter(nil)
+	defer func() { require.NoError(t, manifest.SetSources(nil)) }()
+
+	stderr := new(bytes.Buffer)
+	stdout := logger.ExecuteWithLock(func() {
+		cmd := NewRoot("dev", home)
+		cmd.SetErr(stderr)
+		// show fetches the manifest, which is not available here
+		cmd.SetArgs([]string{"show", "-o", "json", "--insecure-skip-manifest-verify",
+			"--manifest-url", "file://" + filepath.Join(home, "non-existent.json")})
+		require.NoError(t, cmd.Execute())
+	})
+
+	// the warning goes to stderr to keep the json on stdout parsable
+	var actual []interface{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &actual), stdout.String())
+	require.Contains(t, stderr.String(), "[WARNING] the manifest signature verification is skipped")
+}
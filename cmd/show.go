@@ -15,19 +15,50 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 
 	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/manifest"
 )
 
 func newShowCmd(homedir string) *cobra.Command {
-	return &cobra.Command{
-		Use:     "show",
-		Short:   "Show fetched Istio versions",
-		Long:    `Show fetched Istio version`,
-		Example: `getmesh show`,
+	var output string
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show fetched Istio versions",
+		Long: `Show fetched Istio version
+
+The active version is marked with "*" in the table output.
+"IN MANIFEST" and "END OF LIFE" are filled from the manifest, which is served from the cache within its TTL
+and never fetched with --offline. When the manifest cannot be fetched, the cached one is used if any,
+and they are left unknown otherwise.`,
+		Example: `# Show fetched Istio versions
+$ getmesh show
+
+# Show fetched Istio versions in JSON for scripts
+$ getmesh show -o json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return istioctl.PrintFetchedVersions(homedir)
+			ms, err := manifest.FetchManifest()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "[WARNING] failed to fetch the manifest: %v\n", err)
+				if ms, err = manifest.LoadCachedManifest(); err != nil {
+					// show the columns from the manifest as unknown
+					fmt.Fprintf(cmd.ErrOrStderr(), "[WARNING] no cached manifest is available: IN MANIFEST and END OF LIFE are unknown\n")
+					ms = nil
+				} else {
+					fmt.Fprintf(cmd.ErrOrStderr(), "[WARNING] falling back to the cached manifest\n")
+				}
+			}
+
+			ds, err := istioctl.GetInstalledDistributions(homedir, ms)
+			if err != nil {
+				return err
+			}
+			return istioctl.PrintInstalledDistributions(ds, output)
 		},
 	}
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format, one of table, json or yaml")
+	return cmd
 }
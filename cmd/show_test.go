@@ -0,0 +1,85 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func TestShow_manifest(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+	manifest.GlobalManifestURLMux.Lock()
+	defer manifest.GlobalManifestURLMux.Unlock()
+	defer manifest.SetCache("", 0)
+	defer func() { require.NoError(t, manifest.SetSources(nil)) }()
+	defer logger.SetDiagnosticWriter(nil)
+
+	home := t.TempDir()
+	d := &manifest.IstioDistribution{Version: "1.18.2", Flavor: manifest.IstioDistributionFlavorTetrate}
+	require.NoError(t, getmesh.SetIstioVersion(home, d))
+	path := istioctl.GetIstioctlPath(home, d)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, nil, 0755))
+
+	show := func(args ...string) (inManifest *bool, stderr string) {
+		buf := new(bytes.Buffer)
+		stdout := logger.ExecuteWithLock(func() {
+			cmd := NewRoot("dev", home)
+			cmd.SetErr(buf)
+			cmd.SetArgs(append([]string{"show", "-o", "json"}, args...))
+			require.NoError(t, cmd.Execute())
+		})
+
+		var actual []struct {
+			InManifest *bool `json:"in_manifest"`
+		}
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &actual), stdout.String())
+		require.Len(t, actual, 1)
+		return actual[0].InManifest, buf.String()
+	}
+
+	t.Run("unavailable", func(t *testing.T) {
+		inManifest, stderr := show("--manifest-url", "file://"+filepath.Join(home, "non-existent.json"))
+		require.Nil(t, inManifest)
+		require.Contains(t, stderr, "[WARNING] failed to fetch the manifest")
+		require.Contains(t, stderr, "IN MANIFEST and END OF LIFE are unknown")
+	})
+
+	t.Run("fetched", func(t *testing.T) {
+		// the manifest is fetched on the fresh home without any cache
+		raw, err := json.Marshal(&manifest.Manifest{IstioDistributions: []*manifest.IstioDistribution{d}})
+		require.NoError(t, err)
+		mp := filepath.Join(t.TempDir(), "manifest.json")
+		require.NoError(t, os.WriteFile(mp, raw, 0644))
+		t.Setenv("GETMESH_TEST_MANIFEST_PATH", mp)
+
+		inManifest, stderr := show()
+		require.NotNil(t, inManifest)
+		require.True(t, *inManifest)
+		require.NotContains(t, stderr, "failed to fetch the manifest")
+	})
+}
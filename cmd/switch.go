@@ -16,13 +16,13 @@ package cmd
 
 import (
 	"fmt"
-	"strings"
+	"os"
 
 	"github.com/spf13/cobra"
 
+	"github.com/tetratelabs/getmesh/internal/getmesh"
 	"github.com/tetratelabs/getmesh/internal/istioctl"
 	"github.com/tetratelabs/getmesh/internal/manifest"
-	"github.com/tetratelabs/getmesh/internal/manifestchecker"
 	"github.com/tetratelabs/getmesh/internal/util/logger"
 )
 
@@ -56,8 +56,11 @@ $ getmesh switch --version 1.9.0 --flavor=tetrate
 # Switch from active version=1.8.3, flavor=istio and flavor-version=0 to version=1.8.3, flavor=tetrate, flavor-version=1
 $ getmesh switch --flavor tetrate --flavor-version=1
 
-# Switch from active version=1.8.3, flavor=istio and flavor-version=0 to the latest 1.9.x version, flavor=istio and flavor-version=0
+# Switch from active version=1.8.3, flavor=istio and flavor-version=0 to the latest fetched 1.9.x version, flavor=istio and flavor-version=0
 $ getmesh switch --version 1.9
+
+# Switch to the latest fetched version in the range with the same flavor and flavor-version
+$ getmesh switch --version ">=1.17.5 <1.19"
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			d, err := switchParse(homedir, &flag)
@@ -71,7 +74,7 @@ $ getmesh switch --version 1.9
 	flags := cmd.Flags()
 	flags.SortFlags = false
 	flags.StringVarP(&flag.name, "name", "", "", "Name of distribution, e.g. 1.9.0-istio-v0")
-	flags.StringVarP(&flag.version, "version", "", "", "Version of istioctl, e.g. 1.7.4. When --name flag is set, this will not be used.")
+	flags.StringVarP(&flag.version, "version", "", "", "Version of istioctl, e.g. 1.7.4, or the constraint resolved to the latest fetched version, e.g. ~1.18. When --name flag is set, this will not be used.")
 	flags.StringVarP(&flag.flavor, "flavor", "", "", "Flavor of istioctl, e.g. \"tetrate\" or \"tetratefips\" or \"istio\". When --name flag is set, this will not be used.")
 	flags.Int64VarP(&flag.flavorVersion, "flavor-version", "", -1, "Version of the flavor, e.g. 1. When --name flag is set, this will not be used")
 
@@ -89,16 +92,19 @@ func switchParse(homedir string, flags *switchFlags) (*manifest.IstioDistributio
 		d, err := manifest.IstioDistributionFromString(flags.name)
 		if err != nil {
 			return nil, fmt.Errorf("cannot parse given name to %s istio distribution", flags.name)
+		} else if len(d.Variant) != 0 {
+			return nil, fmt.Errorf("the image variant %s in %s does not apply to istioctl", d.Variant, flags.name)
 		}
 		return d, nil
 	}
 
 	// assumption there exists at least one distribution, thus currDistro cannot be nil
 	currDistro, _ := istioctl.GetCurrentExecutable(homedir)
-	return switchHandleDistro(currDistro, flags)
+	fetched, _ := istioctl.GetFetchedVersions(homedir)
+	return switchHandleDistro(currDistro, fetched, flags)
 }
 
-func switchHandleDistro(curr *manifest.IstioDistribution, flags *switchFlags) (*manifest.IstioDistribution, error) {
+func switchHandleDistro(curr *manifest.IstioDistribution, fetched []*manifest.IstioDistribution, flags *switchFlags) (*manifest.IstioDistribution, error) {
 	var version, flavor string
 	var flavorVersion int64
 
@@ -126,36 +132,69 @@ func switchHandleDistro(curr *manifest.IstioDistribution, flags *switchFlags) (*
 		FlavorVersion: flavorVersion,
 	}
 
-	vs := strings.Split(version, ".")
-	if len(vs) != 2 && len(vs) != 3 {
-		return nil, fmt.Errorf("cannot infer the target version, the version %s is invalid", version)
-	}
+	if _, err := manifest.ParseVersion(version); err != nil {
+		// "x.y" or the constraint such as "~1.18" is resolved to the latest fetched version
+		c, err := manifest.ParseConstraint(version)
+		if err != nil {
+			return nil, fmt.Errorf("cannot infer the target version, the version %s is invalid: %v", version, err)
+		}
 
-	if len(vs) == 2 {
-		vs = append(vs, "0")
-		d.Version = strings.Join(vs, ".")
-		ms, err := manifest.FetchManifest()
+		d.Version, err = switchResolveConstraint(c, flavor, flavorVersion, fetched)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("cannot infer the target version: %v", err)
 		}
+	}
+	return d, nil
+}
 
-		if err := manifestchecker.Check(ms); err != nil {
-			return nil, err
+// switch never fetches, so the constraint is resolved against the fetched distributions.
+// The manifest is only consulted to suggest the distribution to fetch when none of them satisfies it.
+func switchResolveConstraint(c *manifest.Constraint, flavor string, flavorVersion int64, fetched []*manifest.IstioDistribution) (string, error) {
+	candidates := make([]*manifest.IstioDistribution, 0, len(fetched))
+	for _, d := range fetched {
+		if d.FlavorVersion == flavorVersion {
+			candidates = append(candidates, d)
 		}
+	}
 
-		latest, _, err := manifest.GetLatestDistribution(d, ms)
-		if err != nil {
-			return nil, err
+	latest, err := manifest.GetLatestDistributionMatching(c, flavor, &manifest.Manifest{IstioDistributions: candidates})
+	if err != nil {
+		return "", err
+	} else if latest != nil {
+		logger.Infof("fallback to %s which is the latest fetched version satisfying %s\n", latest.Version, c.String())
+		return latest.Version, nil
+	}
+
+	err = fmt.Errorf("no fetched %s-v%d distribution satisfies the version %s", flavor, flavorVersion, c.String())
+	if ms, merr := manifest.FetchManifest(); merr == nil {
+		if found, _ := manifest.GetLatestDistributionMatching(c, flavor, ms); found != nil {
+			return "", fmt.Errorf("%v. Please run `getmesh fetch --name %s` first", err, found.String())
 		}
-		d.Version = latest.Version
 	}
-	return d, nil
+	return "", err
 }
 
 func switchExec(homedir string, distribution *manifest.IstioDistribution) error {
-	if err := istioctl.Switch(homedir, distribution); err != nil {
+	if err := switchWithLock(homedir, distribution); err != nil {
 		return err
 	}
 	logger.Infof("istioctl switched to %s now\n", distribution.String())
+
+	if wd, err := os.Getwd(); err == nil {
+		if pinned, path, _ := getmesh.FindPinnedVersion(wd); pinned != nil && !pinned.Equal(distribution) {
+			logger.Warnf("%s pinned by %s still takes effect in this directory\n", pinned.String(), path)
+		}
+	}
 	return nil
 }
+
+// the lock is only held while the installed distribution is activated, not while the target is resolved
+// against the manifest, so that the other getmesh processes are not blocked by the slow manifest sources
+func switchWithLock(homedir string, distribution *manifest.IstioDistribution) error {
+	unlock, err := getmesh.LockHome(homedir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return istioctl.Switch(homedir, distribution)
+}
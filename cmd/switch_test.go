@@ -67,12 +67,17 @@ func Test_switchParse(t *testing.T) {
 	}
 
 	require.NoError(t, getmesh.SetIstioVersion(home, d))
-	require.NoError(t,
-		os.MkdirAll(strings.TrimSuffix(istioctl.GetIstioctlPath(home, d), "/istioctl"), 0755))
 
-	f, err = os.Create(istioctl.GetIstioctlPath(home, d))
-	require.NoError(t, err)
-	defer f.Close()
+	// the fetched distributions, of which 1.7.3 is not in the manifest and 1.7.6 is not fetched
+	for _, d := range []*manifest.IstioDistribution{
+		d,
+		{Version: "1.7.5", Flavor: manifest.IstioDistributionFlavorIstio, FlavorVersion: 0},
+		{Version: "1.7.3", Flavor: manifest.IstioDistributionFlavorIstio, FlavorVersion: 0},
+	} {
+		path := istioctl.GetIstioctlPath(home, d)
+		require.NoError(t, os.MkdirAll(strings.TrimSuffix(path, "/istioctl"), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0755))
+	}
 
 	t.Run("ok", func(t *testing.T) {
 		flag := &switchFlags{version: "1.7.6", flavor: "istio", flavorVersion: 0}
@@ -92,8 +97,26 @@ func Test_switchParse(t *testing.T) {
 		flag := &switchFlags{version: "1.7", flavor: "istio", flavorVersion: 0}
 		distro, err := switchParse(home, flag)
 		require.NoError(t, err)
-		exp := &manifest.IstioDistribution{Version: "1.7.6", Flavor: "istio", FlavorVersion: 0}
+		// the latest fetched one rather than the latest in the manifest
+		exp := &manifest.IstioDistribution{Version: "1.7.5", Flavor: "istio", FlavorVersion: 0}
+		require.Equal(t, distro, exp)
+	})
+	t.Run("constraint", func(t *testing.T) {
+		flag := &switchFlags{version: ">=1.7.0 <1.7.5", flavor: "istio", flavorVersion: 0}
+		distro, err := switchParse(home, flag)
+		require.NoError(t, err)
+		exp := &manifest.IstioDistribution{Version: "1.7.3", Flavor: "istio", FlavorVersion: 0}
 		require.Equal(t, distro, exp)
+
+		flag = &switchFlags{version: "^1.8", flavor: "istio", flavorVersion: 0}
+		_, err = switchParse(home, flag)
+		require.EqualError(t, err, "cannot infer the target version: no fetched istio-v0 distribution satisfies the version ^1.8")
+	})
+	t.Run("constraint not fetched", func(t *testing.T) {
+		flag := &switchFlags{version: "~1.7.6", flavor: "istio", flavorVersion: 0}
+		_, err := switchParse(home, flag)
+		require.EqualError(t, err, "cannot infer the target version: no fetched istio-v0 distribution satisfies the version ~1.7.6. "+
+			"Please run `getmesh fetch --name 1.7.6-istio-v0` first")
 	})
 }
 
@@ -123,9 +146,22 @@ func Test_switchHandleDistro(t *testing.T) {
 			flags: &switchFlags{version: "", flavor: "", flavorVersion: -1},
 			exp:   &manifest.IstioDistribution{Version: "1.7.6", Flavor: "tetratefips", FlavorVersion: 0},
 		},
+		{
+			curr:  &manifest.IstioDistribution{Version: "1.19.3", Flavor: "tetrate", FlavorVersion: 0},
+			flags: &switchFlags{version: "1.20.0-beta.1", flavor: "", flavorVersion: -1},
+			exp:   &manifest.IstioDistribution{Version: "1.20.0-beta.1", Flavor: "tetrate", FlavorVersion: 0},
+		},
 	} {
-		v, err := switchHandleDistro(c.curr, c.flags)
+		v, err := switchHandleDistro(c.curr, nil, c.flags)
 		require.NoError(t, err)
 		require.Equal(t, c.exp, v)
 	}
+
+	t.Run("invalid", func(t *testing.T) {
+		curr := &manifest.IstioDistribution{Version: "1.7.6", Flavor: "tetrate", FlavorVersion: 0}
+		for _, version := range []string{"1.7.6.1", "1.7-beta.1", ">>1.7", "1.x.3", "~"} {
+			_, err := switchHandleDistro(curr, nil, &switchFlags{version: version, flavorVersion: -1})
+			require.Error(t, err, version)
+		}
+	})
 }
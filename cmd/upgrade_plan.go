@@ -0,0 +1,155 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/internal/checkupgrade"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util"
+)
+
+type upgradePlanFlags struct {
+	to, from, flavor, k8sVersion, output string
+}
+
+func newUpgradePlanCmd(homedir string) *cobra.Command {
+	var flag upgradePlanFlags
+
+	cmd := &cobra.Command{
+		Use:   "upgrade-plan",
+		Short: "Plan the upgrade of the control plane across minor versions",
+		Long: `Plan the upgrade of the control plane across minor versions
+
+The plan starts from the lowest control plane version running in the cluster, and ends at the latest distribution
+satisfying --to. Each step upgrades to the latest patch of the furthest minor version allowed by Istio's version skew:
+the in-place upgrade supports the next minor version, and the canary upgrade supports up to two minor versions ahead.
+Every distribution in the plan supports the Kubernetes version of the cluster in the manifest, and the minor versions
+past the end of life are only used when no supported one is reachable in a step.`,
+		Example: `# Plan the upgrade of the control plane in the cluster to the latest 1.20
+$ getmesh upgrade-plan --to 1.20
+
+STEP	       FROM       	        TO        	     UPGRADE      	SECURITY PATCH	END OF LIFE
+ 1  	1.16.7-tetrate-v0	1.18.5-tetrate-v0	      canary      	     true     	2024-01-03
+ 2  	1.18.5-tetrate-v0	1.20.2-tetrate-v0	      canary      	    false     	2024-06-25
+
+Release notes of step 1 (1.18.5-tetrate-v0):
+- https://istio.io/latest/news/releases/1.18.x/announcing-1.18/
+...
+
+# Plan the upgrade without accessing the cluster
+$ getmesh upgrade-plan --from 1.16.7-tetrate-v0 --k8s-version 1.26 --to "~1.20.1"
+
+# Plan the upgrade to the tetratefips flavor in JSON
+$ getmesh upgrade-plan --to 1.20 --flavor tetratefips -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(flag.to) == 0 {
+				return errors.New("--to must be given, e.g. --to 1.20")
+			}
+			target, err := manifest.ParseConstraint(flag.to)
+			if err != nil {
+				return err
+			}
+			if len(flag.flavor) != 0 && !manifest.IsManagedFlavor(flag.flavor) {
+				return fmt.Errorf("unsupported flavor %s: must be one of %s, %s or %s", flag.flavor,
+					manifest.IstioDistributionFlavorTetrate, manifest.IstioDistributionFlavorTetrateFIPS,
+					manifest.IstioDistributionFlavorIstio)
+			}
+
+			current, err := upgradePlanCurrent(homedir, flag.from)
+			if err != nil {
+				return err
+			}
+
+			k8sVersion := flag.k8sVersion
+			if len(k8sVersion) == 0 {
+				if k8sVersion, err = util.GetK8sServerVersion(); err != nil {
+					return fmt.Errorf("%v. Please specify the version by --k8s-version", err)
+				}
+			} else if _, err := manifest.ParseVersion(k8sVersion + ".0"); err != nil {
+				return fmt.Errorf("invalid Kubernetes version %s: must be in the form of 'x.y'", k8sVersion)
+			}
+
+			ms, err := manifest.FetchManifest()
+			if err != nil {
+				return fmt.Errorf("error fetching manifest: %v", err)
+			}
+
+			steps, err := checkupgrade.PlanUpgrade(current, target, ms, &checkupgrade.PlanOptions{
+				Flavor:     flag.flavor,
+				K8SVersion: k8sVersion,
+				Now:        time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			return checkupgrade.PrintUpgradePlan(steps, flag.output)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SortFlags = false
+	flags.StringVarP(&flag.to, "to", "", "", "Target version or constraint, e.g. 1.20, 1.20.2 or \"~1.20.1\"")
+	flags.StringVarP(&flag.from, "from", "", "",
+		"Distribution to upgrade from, e.g. 1.16.7-tetrate-v0. Defaults to the lowest control plane version in the cluster")
+	flags.StringVarP(&flag.flavor, "flavor", "", "",
+		"Flavor of the distributions to upgrade to, e.g. tetrate, tetratefips or istio. Defaults to the current one")
+	flags.StringVarP(&flag.k8sVersion, "k8s-version", "", "",
+		"Kubernetes version of the cluster, e.g. 1.26. Defaults to the server version of the cluster")
+	flags.StringVarP(&flag.output, "output", "o", "table", "Output format, one of table, json or yaml")
+	return cmd
+}
+
+// the distribution given by --from, or else the lowest control plane version in the cluster
+func upgradePlanCurrent(homedir, from string) (*manifest.IstioDistribution, error) {
+	if len(from) != 0 {
+		d, err := manifest.IstioDistributionFromString(from)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse given distribution %s: %v", from, err)
+		}
+		return d, nil
+	}
+
+	w := new(bytes.Buffer)
+	if err := istioctl.ExecWithWriters(homedir, []string{"version", "-o", "json"}, w, nil); err != nil {
+		return nil, fmt.Errorf("error executing istioctl: %v", err)
+	}
+	if strings.Contains(w.String(), istioctl.IstioVersionNoPodRunningMsg) {
+		return nil, errors.New("no control plane is running in the cluster. Please specify the version by --from")
+	}
+
+	var iv istioversion.Version
+	if err := json.Unmarshal(w.Bytes(), &iv); err != nil {
+		return nil, fmt.Errorf("failed to parse istio version results: %v: %s", err, w.Bytes())
+	}
+
+	ret, err := checkupgrade.GetLowestControlPlaneVersion(iv)
+	if err != nil {
+		return nil, err
+	} else if ret == nil {
+		return nil, errors.New("no control plane is running in the cluster. Please specify the version by --from")
+	}
+	return ret, nil
+}
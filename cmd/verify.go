@@ -0,0 +1,129 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/manifestchecker"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func newVerifyCmd(homedir string) *cobra.Command {
+	var refetch, strict bool
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the installed istioctl binaries against the checksums in the manifest",
+		Long: `Verify the installed istioctl binaries against the checksums in the manifest
+
+Each installed istioctl is hashed again and compared with the checksum published in the manifest.
+The command fails if any istioctl is modified or missing, so that it can be run as a periodic check.
+The distributions no longer in the manifest, or without the published checksum, are reported but cannot be verified.
+Matching the checksum recorded in the install receipt is reported as "receipt only", since the receipt can be
+rewritten along with the binary. With --strict, the command also fails if any of them cannot be verified.`,
+		Example: `# Verify all the installed istioctl
+$ getmesh verify
+
+# Verify, and fetch the modified or missing istioctl again
+$ getmesh verify --refetch
+
+# Fail unless all the installed istioctl are verified against the manifest
+$ getmesh verify --strict`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ms, err := manifest.FetchManifest()
+			if err != nil {
+				return fmt.Errorf("error fetching manifest: %v", err)
+			}
+
+			if err := manifestchecker.Check(ms); err != nil {
+				return err
+			}
+
+			// the shared lock keeps `getmesh istioctl` running while hashing. The refetch takes the exclusive lock
+			// by itself, only around the reinstall
+			unlock, err := getmesh.RLockHome(homedir)
+			if err != nil {
+				return err
+			}
+			rs, err := istioctl.Verify(homedir, ms)
+			unlock()
+			if err != nil {
+				return err
+			}
+			istioctl.PrintVerifyResults(rs)
+			if err := verifyHandleBroken(homedir, rs, ms, refetch); err != nil {
+				return err
+			}
+			return verifyHandleStrict(rs, strict)
+		},
+	}
+	cmd.Flags().BoolVarP(&refetch, "refetch", "", false, "Fetch the modified or missing istioctl again")
+	cmd.Flags().BoolVarP(&strict, "strict", "", false,
+		"Fail if any istioctl cannot be verified against the checksum in the manifest, including the ones only matching the receipt")
+	return cmd
+}
+
+func verifyHandleBroken(homedir string, rs []*istioctl.VerifyResult, ms *manifest.Manifest, refetch bool) error {
+	var broken []*manifest.IstioDistribution
+	for _, r := range rs {
+		if r.Broken() {
+			broken = append(broken, r.Distribution)
+		}
+	}
+
+	if len(broken) == 0 {
+		return nil
+	} else if !refetch {
+		return fmt.Errorf("%d of %d istioctl failed verification. Run `getmesh verify --refetch` to fetch them again",
+			len(broken), len(rs))
+	}
+
+	var failed int
+	for _, d := range broken {
+		if err := istioctl.Refetch(homedir, d, ms); err != nil {
+			logger.Warnf("failed to fetch %s again: %v\n", d.String(), err)
+			failed++
+			continue
+		}
+		logger.Infof("%s fetched again\n", d.String())
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to fetch %d of %d istioctl again", failed, len(broken))
+	}
+	return nil
+}
+
+func verifyHandleStrict(rs []*istioctl.VerifyResult, strict bool) error {
+	if !strict {
+		return nil
+	}
+
+	var unverified int
+	for _, r := range rs {
+		if r.Unverified() {
+			unverified++
+		}
+	}
+	if unverified > 0 {
+		return fmt.Errorf("%d of %d istioctl cannot be verified against the manifest", unverified, len(rs))
+	}
+	return nil
+}
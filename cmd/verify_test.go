@@ -0,0 +1,76 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func TestVerify_sharedLock(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	home := t.TempDir()
+	raw, err := json.Marshal(&manifest.Manifest{})
+	require.NoError(t, err)
+	mp := filepath.Join(t.TempDir(), "manifest.json")
+	require.NoError(t, os.WriteFile(mp, raw, 0644))
+	t.Setenv("GETMESH_TEST_MANIFEST_PATH", mp)
+
+	// e.g. `getmesh istioctl` running in another process
+	unlock, err := getmesh.RLockHome(home)
+	require.NoError(t, err)
+	defer unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		logger.ExecuteWithLock(func() {
+			cmd := NewRoot("dev", home)
+			cmd.SetArgs([]string{"verify"})
+			done <- cmd.Execute()
+		})
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("verify is blocked by the shared lock of the home directory")
+	}
+}
+
+func Test_verifyHandleStrict(t *testing.T) {
+	rs := []*istioctl.VerifyResult{
+		{Status: istioctl.VerifyStatusOK},
+		{Status: istioctl.VerifyStatusUnmanaged},
+	}
+	require.NoError(t, verifyHandleStrict(rs, true))
+
+	rs = append(rs, &istioctl.VerifyResult{Status: istioctl.VerifyStatusReceiptOnly})
+	require.NoError(t, verifyHandleStrict(rs, false))
+	err := verifyHandleStrict(rs, true)
+	require.EqualError(t, err, "1 of 3 istioctl cannot be verified against the manifest")
+}
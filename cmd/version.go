@@ -49,7 +49,11 @@ func newVersionCmd(homedir, getmeshVersion string) *cobra.Command {
 				return err
 			}
 
-			logger.Infof("getmesh version: %s\nactive istioctl: %s\n", getmeshVersion, cur.String())
+			active := cur.String()
+			if source := getmesh.GetIstioVersionOverrideSource(); len(source) != 0 {
+				active += fmt.Sprintf(" (set by %s)", source)
+			}
+			logger.Infof("getmesh version: %s\nactive istioctl: %s\n", getmeshVersion, active)
 			k8sCLient, err := util.GetK8sClient()
 			if err != nil {
 				logger.Infof("no active Kubernetes clusters found\n")
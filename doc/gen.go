@@ -41,6 +41,9 @@ func runCommandGen() {
 	}
 	cmdWriteFile(root)
 	for _, c := range root.Commands() {
+		if c.Hidden {
+			continue
+		}
 		cmdWriteFile(c)
 	}
 }
@@ -16,10 +16,12 @@ package e2e
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -124,7 +126,7 @@ istioctl switched to 1.17.4-tetrate-v0 now
 	cmd.Stdout = buf
 	cmd.Stderr = os.Stderr
 	require.NoError(t, cmd.Run())
-	require.Contains(t, buf.String(), `1.16.2-tetrate-v0 (Active)`)
+	require.Contains(t, buf.String(), `*1.16.2-tetrate-v0`)
 }
 
 func TestPrune(t *testing.T) {
@@ -221,15 +223,49 @@ func TestShow(t *testing.T) {
 		getmeshListRequire(t, d.version, d.flavor, d.flavorVersion)
 	}
 
-	cmd := exec.Command("./getmesh", "show")
+	cmd := exec.Command("./getmesh", "show", "-o", "json")
 	buf := new(bytes.Buffer)
 	cmd.Stdout = buf
 	cmd.Stderr = os.Stderr
 	require.NoError(t, cmd.Run())
-	exp := `1.16.2-tetrate-v0
-1.17.4-tetrate-v0
-1.18.0-tetrate-v0 (Active)`
-	require.Contains(t, buf.String(), exp)
+
+	var actual []struct {
+		Name   string `json:"name"`
+		Active bool   `json:"active"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &actual))
+	require.Len(t, actual, 3)
+	for i, exp := range []string{"1.16.2-tetrate-v0", "1.17.4-tetrate-v0", "1.18.0-tetrate-v0"} {
+		require.Equal(t, exp, actual[i].Name)
+		require.Equal(t, i == 2, actual[i].Active)
+	}
+}
+
+func TestList_offline(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("GETMESH_HOME", home)
+	// use the on-disk cache instead of the test manifest
+	t.Setenv("GETMESH_TEST_MANIFEST_PATH", "")
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	cmd := exec.Command("./getmesh", "list", "--manifest-url", "file://"+filepath.ToSlash(filepath.Join(wd, "site", "manifest.json")))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	require.NoError(t, cmd.Run())
+
+	// the offline message is written to stderr
+	cmd = exec.Command("./getmesh", "list", "-o", "json", "--offline")
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	require.NoError(t, cmd.Run(), stderr.String())
+
+	var actual []struct {
+		Version string `json:"version"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &actual), stdout.String())
+	require.NotEmpty(t, actual)
+	require.Contains(t, stderr.String(), "offline mode: using the cached manifest")
 }
 
 func TestSwitch(t *testing.T) {
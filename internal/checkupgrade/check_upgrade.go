@@ -152,19 +152,33 @@ func getLatestPatchInManifestMsg(target *manifest.IstioDistribution, ms *manifes
 	}
 
 	if foundLatest == nil {
-		return fmt.Sprintf("- The minor version %s is no longer supported by getmesh. "+
-			"We recommend you use the higher minor versions in \"getmesh list\"\n", tg), false, nil
+		msg := fmt.Sprintf("- The minor version %s is no longer supported by getmesh. "+
+			"We recommend you use the higher minor versions in \"getmesh list\"", tg)
+		latest, err := manifest.GetLatestDistributionInFlavor(target.Flavor, ms)
+		if err != nil {
+			return "", false, err
+		}
+		if latest != nil {
+			if ok, _ := latest.GreaterThan(target); ok {
+				msg += fmt.Sprintf(", the latest of which is %s", latest.String())
+			}
+		}
+		return msg + "\n", false, nil
 	}
 
 	if foundLatest.Equal(target) {
 		return fmt.Sprintf("- %s is the latest version in %s\n", target.String(), tg), true, nil
 	}
 
+	// recommend the same image variant as the running one
+	recommended := *foundLatest
+	recommended.Variant = target.Variant
+
 	msg := fmt.Sprintf("- There is the available patch for the minor version %s", tg)
 	if includeSecurityPatch {
-		msg += fmt.Sprintf(" which includes **security upgrades**. We strongly recommend upgrading all %s versions -> %s\n", tg, foundLatest.String())
+		msg += fmt.Sprintf(" which includes **security upgrades**. We strongly recommend upgrading all %s versions -> %s\n", tg, recommended.String())
 	} else {
-		msg += fmt.Sprintf(". We recommend upgrading all %s versions -> %s\n", tg, foundLatest.String())
+		msg += fmt.Sprintf(". We recommend upgrading all %s versions -> %s\n", tg, recommended.String())
 
 	}
 	return msg, false, nil
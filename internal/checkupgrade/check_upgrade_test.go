@@ -382,6 +382,7 @@ func Test_getLatestPatchInManifestMsg(t *testing.T) {
 		require.False(t, ok)
 		require.Contains(t, msg, "The minor version 1.0-tetrate is no longer supported by getmesh.")
 		require.Contains(t, msg, "getmesh list")
+		require.Contains(t, msg, "the latest of which is 1.8.10-tetrate-v20")
 		t.Log(msg)
 
 		msg, ok, err = getLatestPatchInManifestMsg(&manifest.IstioDistribution{
@@ -391,10 +392,35 @@ func Test_getLatestPatchInManifestMsg(t *testing.T) {
 		require.False(t, ok)
 		require.Contains(t, msg, "The minor version 1.10-tetratefips is no longer supported by getmesh.")
 		require.Contains(t, msg, "getmesh list")
+		// the only supported minor version is older
+		require.NotContains(t, msg, "the latest of which")
 		t.Log(msg)
 
 	})
 
+	t.Run("variant", func(t *testing.T) {
+		ms := &manifest.Manifest{IstioDistributions: []*manifest.IstioDistribution{
+			{Version: "1.18.5", Flavor: "tetrate", FlavorVersion: 0},
+			{Version: "1.18.2", Flavor: "tetrate", FlavorVersion: 0},
+		}}
+
+		msg, ok, err := getLatestPatchInManifestMsg(&manifest.IstioDistribution{
+			Version: "1.18.2", Flavor: "tetrate", FlavorVersion: 0, Variant: "distroless",
+		}, ms)
+		require.NoError(t, err)
+		require.False(t, ok)
+		require.Contains(t, msg, "-> 1.18.5-tetrate-v0-distroless")
+		// the manifest is not modified
+		require.Empty(t, ms.IstioDistributions[0].Variant)
+
+		msg, ok, err = getLatestPatchInManifestMsg(&manifest.IstioDistribution{
+			Version: "1.18.5", Flavor: "tetrate", FlavorVersion: 0, Variant: "distroless",
+		}, ms)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "- 1.18.5-tetrate-v0-distroless is the latest version in 1.18-tetrate\n", msg)
+	})
+
 	t.Run("updated", func(t *testing.T) {
 		ms := []*manifest.IstioDistribution{
 			{Version: "1.8.10", Flavor: "tetrate", FlavorVersion: 10},
@@ -649,6 +675,18 @@ func Test_getDataPlaneVersions(t *testing.T) {
 					"1.6-tetrate": {Version: "1.6.1", Flavor: "tetrate", FlavorVersion: 1},
 				},
 			},
+			{
+				in: []istioversion.ProxyInfo{
+					{IstioVersion: "1.18.2-distroless"}, // to be ignored
+					{IstioVersion: "1.18.2-tetrate-v0-distroless"},
+					{IstioVersion: "1.18.3-tetrate-v0"},
+					{IstioVersion: "1.17.5-tetratefips-v1-debug"},
+				},
+				exp: map[string]*manifest.IstioDistribution{
+					"1.18-tetrate":     {Version: "1.18.2", Flavor: "tetrate", FlavorVersion: 0, Variant: "distroless"},
+					"1.17-tetratefips": {Version: "1.17.5", Flavor: "tetratefips", FlavorVersion: 1, Variant: "debug"},
+				},
+			},
 		} {
 			t.Run(fmt.Sprintf("%d-th", i), func(t *testing.T) {
 				actual, err := getDataPlaneVersions(&c.in)
@@ -0,0 +1,341 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+// the number of minor versions a canary upgrade can skip over, e.g. 1.16 -> 1.18.
+// The in-place upgrade only supports the next minor version.
+const (
+	maxInPlaceMinorSkew = 1
+	maxCanaryMinorSkew  = 2
+)
+
+// PlanOptions are the conditions every distribution in the upgrade plan must satisfy.
+type PlanOptions struct {
+	// Flavor of the distributions to upgrade to. Defaults to the flavor of the current one.
+	Flavor string
+	// K8SVersion is the minor version of the cluster, e.g. "1.26". The compatibility is not checked if empty.
+	K8SVersion string
+	// Now is compared with the end of life of the distributions.
+	Now time.Time
+}
+
+// PlanStep is a hop of the upgrade plan.
+type PlanStep struct {
+	From string `json:"from" yaml:"from"`
+	To   string `json:"to" yaml:"to"`
+	// CanaryOnly is true if the hop skips a minor version, which is only supported by the canary upgrade.
+	CanaryOnly bool `json:"canary_only" yaml:"canary_only"`
+	// IncludesSecurityPatch is true if any distribution after From up to To is a security patch.
+	IncludesSecurityPatch bool `json:"includes_security_patch" yaml:"includes_security_patch"`
+	// EndOfLife of To. It is past when no supported minor version is reachable in this hop.
+	EndOfLife string `json:"end_of_life,omitempty" yaml:"end_of_life,omitempty"`
+	// ReleaseNotes of the distributions after From up to To in the ascending order.
+	ReleaseNotes []string `json:"release_notes,omitempty" yaml:"release_notes,omitempty"`
+}
+
+// PlanUpgrade computes the sequence of hops from the current distribution to the latest distribution satisfying the
+// target constraint. Each hop goes as far as the canary upgrade allows, preferring the minor versions supported on the
+// cluster's Kubernetes and not past the end of life. It returns no steps if the current one is already the target.
+func PlanUpgrade(current *manifest.IstioDistribution, target *manifest.Constraint, ms *manifest.Manifest,
+	opts *PlanOptions) ([]*PlanStep, error) {
+	if current.IsUpstream() {
+		// the upstream build in the cluster is the "istio" flavor in the manifest
+		current = &manifest.IstioDistribution{Version: current.Version, Flavor: manifest.IstioDistributionFlavorIstio}
+	}
+	flavor := opts.Flavor
+	if len(flavor) == 0 {
+		flavor = current.Flavor
+	}
+
+	cv, err := current.ParseVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &planner{ms: ms, flavor: flavor, opts: opts}
+	dest, err := p.latest(func(v *manifest.Version) bool { return target.Check(v) }, false)
+	if err != nil {
+		return nil, err
+	} else if dest == nil {
+		return nil, fmt.Errorf("no %s distribution satisfies %s%s and is not past the end of life",
+			flavor, target.String(), p.k8sCondition())
+	}
+
+	dv, err := dest.ParseVersion()
+	if err != nil {
+		return nil, err
+	}
+	if c := dv.Compare(cv); c < 0 || (c == 0 && dest.Flavor == current.Flavor && dest.FlavorVersion <= current.FlavorVersion) {
+		return nil, nil
+	} else if dv.Major() != cv.Major() {
+		return nil, fmt.Errorf("upgrading across the major versions from %s to %s is not supported",
+			current.String(), dest.String())
+	}
+
+	var steps []*PlanStep
+	from, fv := current, cv
+	for {
+		next := dest
+		if !fv.SameMinor(dv) {
+			if next, err = p.nextHop(fv, dv, dest); err != nil {
+				return nil, err
+			} else if next == nil {
+				return nil, fmt.Errorf("no %s distribution of %d.%d or %d.%d%s to upgrade from %s",
+					flavor, fv.Major(), fv.Minor()+1, fv.Major(), fv.Minor()+maxCanaryMinorSkew, p.k8sCondition(), from.String())
+			}
+		}
+
+		nv, err := next.ParseVersion()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, p.step(from, fv, next, nv))
+		if next == dest {
+			return steps, nil
+		}
+		from, fv = next, nv
+	}
+}
+
+type planner struct {
+	ms     *manifest.Manifest
+	flavor string
+	opts   *PlanOptions
+}
+
+// the furthest hop within the canary skew, preferring the minor versions not past the end of life
+func (p *planner) nextHop(from, dest *manifest.Version, d *manifest.IstioDistribution) (*manifest.IstioDistribution, error) {
+	for _, allowEOL := range []bool{false, true} {
+		for skew := maxCanaryMinorSkew; skew > 0; skew-- {
+			minor := from.Minor() + skew
+			if minor > dest.Minor() {
+				continue
+			} else if minor == dest.Minor() {
+				return d, nil
+			}
+
+			next, err := p.latest(func(v *manifest.Version) bool {
+				return v.Major() == from.Major() && v.Minor() == minor && !v.IsPreRelease()
+			}, allowEOL)
+			if err != nil {
+				return nil, err
+			} else if next != nil {
+				return next, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// the latest distribution of the flavor whose version matches, supporting the cluster's Kubernetes
+func (p *planner) latest(match func(*manifest.Version) bool, allowEOL bool) (*manifest.IstioDistribution, error) {
+	var ret *manifest.IstioDistribution
+	for _, d := range p.ms.IstioDistributions {
+		if d.Flavor != p.flavor || !p.supportsK8S(d) {
+			continue
+		}
+
+		v, err := d.ParseVersion()
+		if err != nil {
+			return nil, err
+		} else if !match(v) {
+			continue
+		}
+
+		if !allowEOL {
+			if eol, err := p.pastEndOfLife(d); err != nil {
+				return nil, err
+			} else if eol {
+				continue
+			}
+		}
+
+		if ret == nil {
+			ret = d
+		} else if ok, err := d.GreaterThan(ret); err != nil {
+			return nil, err
+		} else if ok {
+			ret = d
+		}
+	}
+	return ret, nil
+}
+
+// the distributions without the supported versions in the manifest are regarded as compatible
+func (p *planner) supportsK8S(d *manifest.IstioDistribution) bool {
+	if len(p.opts.K8SVersion) == 0 || len(d.K8SVersions) == 0 {
+		return true
+	}
+	for _, v := range d.K8SVersions {
+		if v == p.opts.K8SVersion {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *planner) pastEndOfLife(d *manifest.IstioDistribution) (bool, error) {
+	if len(d.EndOfLife) == 0 {
+		return false, nil
+	}
+	eol, err := time.Parse("2006-01-02", d.EndOfLife)
+	if err != nil {
+		return false, fmt.Errorf("invalid end of life %s of %s: %v", d.EndOfLife, d.String(), err)
+	}
+	return p.opts.Now.After(eol), nil
+}
+
+func (p *planner) k8sCondition() string {
+	if len(p.opts.K8SVersion) == 0 {
+		return ""
+	}
+	return ", supports Kubernetes " + p.opts.K8SVersion
+}
+
+func (p *planner) step(from *manifest.IstioDistribution, fv *manifest.Version,
+	to *manifest.IstioDistribution, tv *manifest.Version) *PlanStep {
+	ret := &PlanStep{
+		From:       from.String(),
+		To:         to.String(),
+		CanaryOnly: tv.Major() == fv.Major() && tv.Minor()-fv.Minor() > maxInPlaceMinorSkew,
+		EndOfLife:  to.EndOfLife,
+	}
+
+	// the distributions in (from, to] of the flavor in the ascending order
+	var between []*manifest.IstioDistribution
+	for _, d := range p.ms.IstioDistributions {
+		v, err := d.ParseVersion()
+		if err != nil || d.Flavor != p.flavor || v.Compare(fv) <= 0 || v.Compare(tv) > 0 {
+			continue
+		}
+		if v.Compare(tv) == 0 && d.FlavorVersion > to.FlavorVersion {
+			continue
+		}
+		between = append(between, d)
+	}
+	// all of them are parsable here
+	sort.SliceStable(between, func(i, j int) bool {
+		ok, _ := between[j].GreaterThan(between[i])
+		return ok
+	})
+
+	seen := map[string]struct{}{}
+	for _, d := range between {
+		if d.IsSecurityPatch {
+			ret.IncludesSecurityPatch = true
+		}
+		for _, n := range d.ReleaseNotes {
+			if _, ok := seen[n]; !ok {
+				seen[n] = struct{}{}
+				ret.ReleaseNotes = append(ret.ReleaseNotes, n)
+			}
+		}
+	}
+	return ret
+}
+
+// GetLowestControlPlaneVersion returns the lowest version among the control planes, which the upgrade starts from.
+// It returns nil if no control plane is running.
+func GetLowestControlPlaneVersion(iv istioversion.Version) (*manifest.IstioDistribution, error) {
+	if iv.MeshVersion == nil {
+		return nil, nil
+	}
+
+	var ret *manifest.IstioDistribution
+	for _, raw := range *iv.MeshVersion {
+		v, err := manifest.IstioDistributionFromString(raw.Info.Version)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing control's version %s: %v", raw.Info.Version, err)
+		}
+
+		if ret == nil {
+			ret = v
+		} else if ok, err := ret.GreaterThan(v); err != nil {
+			return nil, err
+		} else if ok {
+			ret = v
+		}
+	}
+	return ret, nil
+}
+
+// PrintUpgradePlan prints the steps in the format, "table", "json" or "yaml".
+func PrintUpgradePlan(steps []*PlanStep, format string) error {
+	if steps == nil {
+		steps = []*PlanStep{}
+	}
+
+	switch format {
+	case "json":
+		raw, err := json.MarshalIndent(steps, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling upgrade plan: %v", err)
+		}
+		logger.Outputf("%s\n", raw)
+	case "yaml":
+		raw, err := yaml.Marshal(steps)
+		if err != nil {
+			return fmt.Errorf("error marshaling upgrade plan: %v", err)
+		}
+		logger.Outputf("%s", raw)
+	case "table":
+		if len(steps) == 0 {
+			logger.Infof("nothing to upgrade\n")
+			return nil
+		}
+
+		data := make([][]string, len(steps))
+		for i, s := range steps {
+			upgrade := "in-place or canary"
+			if s.CanaryOnly {
+				upgrade = "canary"
+			}
+			data[i] = []string{strconv.Itoa(i + 1), s.From, s.To, upgrade, strconv.FormatBool(s.IncludesSecurityPatch), s.EndOfLife}
+		}
+
+		table := tablewriter.NewWriter(logger.GetWriter())
+		table.SetHeader([]string{"STEP", "FROM", "TO", "UPGRADE", "SECURITY PATCH", "END OF LIFE"})
+		util.FlushTable(table, data)
+
+		for i, s := range steps {
+			if len(s.ReleaseNotes) == 0 {
+				continue
+			}
+			logger.Infof("\nRelease notes of step %d (%s):\n", i+1, s.To)
+			for _, n := range s.ReleaseNotes {
+				logger.Infof("- %s\n", n)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported output format %s: must be one of table, json or yaml", format)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkupgrade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	istioversion "istio.io/pkg/version"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func TestPlanUpgrade(t *testing.T) {
+	ms := &manifest.Manifest{
+		IstioDistributions: []*manifest.IstioDistribution{
+			{Version: "1.20.2", Flavor: "tetratefips", K8SVersions: []string{"1.26", "1.27"}, EndOfLife: "2025-01-01"},
+			{Version: "1.20.2", Flavor: "tetrate", K8SVersions: []string{"1.27"}, EndOfLife: "2025-01-01",
+				ReleaseNotes: []string{"notes-1.20.2"}},
+			{Version: "1.20.1", Flavor: "tetrate", K8SVersions: []string{"1.26", "1.27"}, EndOfLife: "2025-01-01",
+				ReleaseNotes: []string{"notes-1.20.1"}},
+			{Version: "1.20.0-rc.0", Flavor: "tetrate", K8SVersions: []string{"1.26", "1.27"}},
+			{Version: "1.19.4", Flavor: "tetrate", K8SVersions: []string{"1.25", "1.26", "1.27"}, EndOfLife: "2024-09-01"},
+			{Version: "1.18.5", Flavor: "tetrate", K8SVersions: []string{"1.25", "1.26"}, EndOfLife: "2024-06-01",
+				IsSecurityPatch: true, ReleaseNotes: []string{"notes-1.18.5", "notes-1.18"}},
+			{Version: "1.18.3", Flavor: "tetrate", K8SVersions: []string{"1.25", "1.26"}, EndOfLife: "2024-06-01",
+				ReleaseNotes: []string{"notes-1.18.3", "notes-1.18"}},
+			{Version: "1.17.8", Flavor: "tetrate", K8SVersions: []string{"1.24", "1.25", "1.26"}, EndOfLife: "2023-10-01"},
+			{Version: "1.16.7", Flavor: "tetrate", K8SVersions: []string{"1.24", "1.25"}, EndOfLife: "2023-06-01"},
+		},
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	plan := func(t *testing.T, from, to string, opts *PlanOptions) ([]*PlanStep, error) {
+		current, err := manifest.IstioDistributionFromString(from)
+		require.NoError(t, err)
+		target, err := manifest.ParseConstraint(to)
+		require.NoError(t, err)
+		opts.Now = now
+		return PlanUpgrade(current, target, ms, opts)
+	}
+
+	t.Run("canary hops", func(t *testing.T) {
+		actual, err := plan(t, "1.16.7-tetrate-v0", "1.20", &PlanOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []*PlanStep{
+			{From: "1.16.7-tetrate-v0", To: "1.18.5-tetrate-v0", CanaryOnly: true, IncludesSecurityPatch: true,
+				EndOfLife: "2024-06-01", ReleaseNotes: []string{"notes-1.18.3", "notes-1.18", "notes-1.18.5"}},
+			{From: "1.18.5-tetrate-v0", To: "1.20.2-tetrate-v0", CanaryOnly: true,
+				EndOfLife: "2025-01-01", ReleaseNotes: []string{"notes-1.20.1", "notes-1.20.2"}},
+		}, actual)
+	})
+
+	t.Run("k8s version", func(t *testing.T) {
+		actual, err := plan(t, "1.16.7-tetrate-v0", "1.20", &PlanOptions{K8SVersion: "1.25"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "supports Kubernetes 1.25")
+
+		actual, err = plan(t, "1.16.7-tetrate-v0", "1.20", &PlanOptions{K8SVersion: "1.26"})
+		require.NoError(t, err)
+		require.Len(t, actual, 2)
+		require.Equal(t, "1.18.5-tetrate-v0", actual[0].To)
+		require.Equal(t, "1.20.1-tetrate-v0", actual[1].To)
+	})
+
+	t.Run("in-place hop", func(t *testing.T) {
+		actual, err := plan(t, "1.19.4-tetrate-v0", "1.20", &PlanOptions{K8SVersion: "1.27"})
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		require.Equal(t, "1.20.2-tetrate-v0", actual[0].To)
+		require.False(t, actual[0].CanaryOnly)
+	})
+
+	t.Run("prefer supported minor versions", func(t *testing.T) {
+		// 1.17 is past the end of life, but the only one reachable from 1.15
+		actual, err := plan(t, "1.15.0-tetrate-v0", "1.19", &PlanOptions{})
+		require.NoError(t, err)
+		require.Len(t, actual, 2)
+		require.Equal(t, "1.17.8-tetrate-v0", actual[0].To)
+		require.Equal(t, "2023-10-01", actual[0].EndOfLife)
+		require.Equal(t, "1.19.4-tetrate-v0", actual[1].To)
+
+		// the target past the end of life is never chosen
+		_, err = plan(t, "1.15.0-tetrate-v0", "1.17", &PlanOptions{})
+		require.Error(t, err)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		_, err := plan(t, "1.13.0-tetrate-v0", "1.18", &PlanOptions{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no tetrate distribution of 1.14 or 1.15")
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		actual, err := plan(t, "1.20.2-tetrate-v0", "1.20", &PlanOptions{})
+		require.NoError(t, err)
+		require.Empty(t, actual)
+
+		actual, err = plan(t, "1.20.3-tetrate-v0", "1.20", &PlanOptions{})
+		require.NoError(t, err)
+		require.Empty(t, actual)
+	})
+
+	t.Run("flavor", func(t *testing.T) {
+		actual, err := plan(t, "1.18.5-tetrate-v0-distroless", "1.20", &PlanOptions{Flavor: "tetratefips"})
+		require.NoError(t, err)
+		require.Equal(t, []*PlanStep{
+			{From: "1.18.5-tetrate-v0-distroless", To: "1.20.2-tetratefips-v0", CanaryOnly: true, EndOfLife: "2025-01-01"},
+		}, actual)
+
+		// no upstream distribution in the manifest
+		_, err = plan(t, "1.18.5", "1.20", &PlanOptions{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no istio distribution")
+	})
+
+	t.Run("pre-release", func(t *testing.T) {
+		actual, err := plan(t, "1.19.4-tetrate-v0", "1.20.0-rc.0", &PlanOptions{})
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		require.Equal(t, "1.20.0-rc.0-tetrate-v0", actual[0].To)
+	})
+
+	t.Run("major version", func(t *testing.T) {
+		_, err := plan(t, "0.8.0-tetrate-v0", "1.20", &PlanOptions{})
+		require.Error(t, err)
+	})
+}
+
+func TestGetLowestControlPlaneVersion(t *testing.T) {
+	actual, err := GetLowestControlPlaneVersion(istioversion.Version{})
+	require.NoError(t, err)
+	require.Nil(t, actual)
+
+	actual, err = GetLowestControlPlaneVersion(istioversion.Version{MeshVersion: &istioversion.MeshInfo{
+		{Info: istioversion.BuildInfo{Version: "1.18.2-tetrate-v0"}},
+		{Info: istioversion.BuildInfo{Version: "1.17.5-tetrate-v0-distroless"}},
+		{Info: istioversion.BuildInfo{Version: "1.18.0-tetrate-v0"}},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "1.17.5-tetrate-v0-distroless", actual.String())
+
+	_, err = GetLowestControlPlaneVersion(istioversion.Version{MeshVersion: &istioversion.MeshInfo{
+		{Info: istioversion.BuildInfo{Version: "1.18"}},
+	}})
+	require.Error(t, err)
+}
+
+func TestPrintUpgradePlan(t *testing.T) {
+	steps := []*PlanStep{
+		{From: "1.16.7-tetrate-v0", To: "1.18.5-tetrate-v0", CanaryOnly: true, IncludesSecurityPatch: true,
+			EndOfLife: "2024-06-01", ReleaseNotes: []string{"notes-1.18.5"}},
+		{From: "1.18.5-tetrate-v0", To: "1.19.4-tetrate-v0", EndOfLife: "2024-09-01"},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintUpgradePlan(steps, "table"))
+		})
+		require.Equal(t, `STEP	      FROM       	       TO        	     UPGRADE      	SECURITY PATCH	END OF LIFE 
+ 1  	1.16.7-tetrate-v0	1.18.5-tetrate-v0	      canary      	     true     	2024-06-01 	
+ 2  	1.18.5-tetrate-v0	1.19.4-tetrate-v0	in-place or canary	    false     	2024-09-01 	
+
+Release notes of step 1 (1.18.5-tetrate-v0):
+- notes-1.18.5
+`, buf.String())
+
+		buf = logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintUpgradePlan(nil, "table"))
+		})
+		require.Equal(t, "nothing to upgrade\n", buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintUpgradePlan(steps[1:], "json"))
+		})
+		require.JSONEq(t, `[{"from":"1.18.5-tetrate-v0","to":"1.19.4-tetrate-v0","canary_only":false,
+"includes_security_patch":false,"end_of_life":"2024-09-01"}]`, buf.String())
+
+		buf = logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintUpgradePlan(nil, "json"))
+		})
+		require.JSONEq(t, `[]`, buf.String())
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		require.Error(t, PrintUpgradePlan(steps, "wide"))
+	})
+}
@@ -16,6 +16,7 @@ package getmesh
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -23,6 +24,8 @@ import (
 	"sync"
 
 	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util"
+	"github.com/tetratelabs/getmesh/internal/util/filelock"
 )
 
 // GlobalConfigMux for test purpose
@@ -31,65 +34,173 @@ var GlobalConfigMux sync.Mutex
 type Config struct {
 	IstioDistribution *manifest.IstioDistribution `json:"istio_distribution"`
 	DefaultHub        string                      `json:"default_hub,omitempty"`
+	// ManifestSources are tried in order when fetching the manifest. Defaults to the Tetrate hosted manifest.
+	ManifestSources []manifest.Source `json:"manifest_sources,omitempty"`
+	// ManifestCacheTTL is the duration in which the cached manifest is used without revalidation, e.g. "30m".
+	ManifestCacheTTL string `json:"manifest_cache_ttl,omitempty"`
+	// ManifestPublicKeys are base64 encoded ed25519 public keys trusted for manifest verification
+	// in addition to the built-in one.
+	ManifestPublicKeys []string `json:"manifest_public_keys,omitempty"`
+	// RequireManifestSignature rejects the manifest when no public key is trusted instead of warning.
+	// Once any key is trusted, the manifest without the valid signature is always rejected.
+	RequireManifestSignature bool `json:"require_manifest_signature,omitempty"`
+	// AutoFetch enables fetching the pinned istioctl automatically when it is not fetched yet.
+	AutoFetch bool `json:"auto_fetch,omitempty"`
+	// HTTPProxy is the URL of the proxy for downloading the manifest and istioctl,
+	// used in place of HTTPS_PROXY and HTTP_PROXY environment variables.
+	HTTPProxy string `json:"http_proxy,omitempty"`
+	// CABundle is the path to the PEM encoded CA certificates trusted in addition to the system ones.
+	CABundle string `json:"ca_bundle,omitempty"`
+	// HTTPTimeout is the timeout for connecting and waiting for the response headers, e.g. "30s".
+	HTTPTimeout string `json:"http_timeout,omitempty"`
+	// HTTPRetries is the number of retries on connection errors and 5xx responses. Defaults to 3.
+	HTTPRetries *int `json:"http_retries,omitempty"`
 }
 
-var currentConfig Config
+var (
+	currentConfig Config
+	// guards currentConfig read and reloaded by the concurrent fetches in the same process,
+	// which the file locks serialize but do not synchronize for the memory
+	currentConfigMux sync.RWMutex
+
+	// the distribution used in place of the one in config.json in this process, e.g. pinned by .getmesh-version
+	istioVersionOverride       *manifest.IstioDistribution
+	istioVersionOverrideSource string
+
+	// the version of getmesh itself
+	getmeshVersion = "dev"
+)
+
+// SetVersion sets the version of the running getmesh.
+func SetVersion(v string) {
+	getmeshVersion = v
+}
+
+// GetVersion returns the version of the running getmesh.
+func GetVersion() string {
+	return getmeshVersion
+}
 
 // for switch
 func SetIstioVersion(homedir string, d *manifest.IstioDistribution) error {
-	configPath := getConfigPath(homedir)
-	currentConfig.IstioDistribution = d
-	raw, err := json.Marshal(currentConfig)
-	if err != nil {
-		return fmt.Errorf("error marshaling config: %v", err)
-	}
-	if err := ioutil.WriteFile(configPath, raw, 0644); err != nil {
-		return fmt.Errorf("error writing configuration at %s: %v", configPath, err)
-	}
-	return nil
+	return updateConfig(homedir, func(c *Config) {
+		c.IstioDistribution = d
+	})
 }
 
 // for default-hub
 func SetDefaultHub(homedir, hub string) error {
-	configPath := getConfigPath(homedir)
-	currentConfig.DefaultHub = hub
-	raw, err := json.Marshal(currentConfig)
+	return updateConfig(homedir, func(c *Config) {
+		c.DefaultHub = hub
+	})
+}
+
+func GetActiveConfig() Config {
+	currentConfigMux.RLock()
+	ret := currentConfig
+	currentConfigMux.RUnlock()
+	if istioVersionOverride != nil {
+		ret.IstioDistribution = istioVersionOverride
+	}
+	return ret
+}
+
+// SetIstioVersionOverride makes the distribution active in this process without changing config.json.
+// The source describes where it comes from, e.g. the path to .getmesh-version. Passing nil clears the override.
+func SetIstioVersionOverride(d *manifest.IstioDistribution, source string) {
+	istioVersionOverride, istioVersionOverrideSource = d, source
+}
+
+// GetIstioVersionOverrideSource returns the source of the overriding distribution, or empty if not overridden.
+func GetIstioVersionOverrideSource() string {
+	if istioVersionOverride == nil {
+		return ""
+	}
+	return istioVersionOverrideSource
+}
+
+func InitConfig(homedir string) error {
+	err := loadConfig(homedir)
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	unlock, err := filelock.Lock(filepath.Join(homedir, configLockName))
 	if err != nil {
-		return fmt.Errorf("error marshaling config: %v", err)
+		return err
 	}
-	if err := ioutil.WriteFile(configPath, raw, 0644); err != nil {
-		return fmt.Errorf("error writing configuration at %s: %v", configPath, err)
+	defer unlock()
+
+	// another process may have created it while waiting for the lock
+	if err := loadConfig(homedir); !errors.Is(err, os.ErrNotExist) {
+		return err
 	}
+
+	c := Config{}
+	if err := writeConfig(homedir, &c); err != nil {
+		return err
+	}
+	setCurrentConfig(c)
 	return nil
 }
 
-func GetActiveConfig() Config {
-	return currentConfig
+// update the configuration on the disk under the lock, so that the concurrent updates by other processes are not lost
+func updateConfig(homedir string, update func(c *Config)) error {
+	unlock, err := filelock.Lock(filepath.Join(homedir, configLockName))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := loadConfig(homedir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	currentConfigMux.RLock()
+	c := currentConfig
+	currentConfigMux.RUnlock()
+	update(&c)
+	if err := writeConfig(homedir, &c); err != nil {
+		return err
+	}
+	setCurrentConfig(c)
+	return nil
 }
 
-func InitConfig(homedir string) error {
+// load the configuration on the disk into currentConfig. The error wraps os.ErrNotExist if it does not exist.
+func loadConfig(homedir string) error {
 	configPath := getConfigPath(homedir)
-	_, err := os.Stat(configPath)
-	if err == nil {
-		raw, err := ioutil.ReadFile(configPath)
-		if err != nil {
-			return fmt.Errorf("read configuration file at %s: %v", configPath, err)
-		}
-		if err := json.Unmarshal(raw, &currentConfig); err != nil {
-			return fmt.Errorf("error unmarshalling configuration for %s: %v", configPath, err)
-		}
-		return nil
-	} else if !os.IsNotExist(err) && err != nil {
-		return fmt.Errorf("failed to open configuration file at %s: %v", configPath, err)
+	raw, err := ioutil.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("configuration file at %s: %w", configPath, os.ErrNotExist)
+	} else if err != nil {
+		return fmt.Errorf("read configuration file at %s: %v", configPath, err)
+	}
+
+	var c Config
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return fmt.Errorf("error unmarshalling configuration for %s: %v", configPath, err)
 	}
+	setCurrentConfig(c)
+	return nil
+}
 
-	currentConfig = Config{}
-	raw, err := json.Marshal(currentConfig)
+func setCurrentConfig(c Config) {
+	currentConfigMux.Lock()
+	defer currentConfigMux.Unlock()
+	currentConfig = c
+}
+
+// write the configuration into a temporary file then rename it,
+// so that readers never see a partially written config.json
+func writeConfig(homedir string, c *Config) error {
+	configPath := getConfigPath(homedir)
+	raw, err := json.Marshal(c)
 	if err != nil {
 		return fmt.Errorf("error marshaling config: %v", err)
 	}
 
-	if err := ioutil.WriteFile(configPath, raw, 0644); err != nil {
+	if err := util.WriteFileAtomic(configPath, raw, 0644); err != nil {
 		return fmt.Errorf("error writing configuration at %s: %v", configPath, err)
 	}
 	return nil
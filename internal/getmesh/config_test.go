@@ -88,6 +88,74 @@ func TestInitConfig(t *testing.T) {
 
 }
 
+func Test_updateConfig(t *testing.T) {
+	GlobalConfigMux.Lock()
+	defer GlobalConfigMux.Unlock()
+	home := t.TempDir()
+	currentConfig = Config{}
+
+	d := &manifest.IstioDistribution{
+		Version:       "1.8.1",
+		Flavor:        manifest.IstioDistributionFlavorTetrate,
+		FlavorVersion: 0,
+	}
+	require.NoError(t, SetIstioVersion(home, d))
+
+	// another process switches the version after this process loaded the configuration
+	other := &manifest.IstioDistribution{
+		Version:       "1.9.0",
+		Flavor:        manifest.IstioDistributionFlavorIstio,
+		FlavorVersion: 0,
+	}
+	raw, err := json.Marshal(Config{IstioDistribution: other})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(getConfigPath(home), raw, 0644))
+
+	// the change of the other process must not be lost
+	require.NoError(t, SetDefaultHub(home, "gcr.io/istio-testing"))
+	b, err := ioutil.ReadFile(getConfigPath(home))
+	require.NoError(t, err)
+	var actual Config
+	require.NoError(t, json.Unmarshal(b, &actual))
+	assert.Equal(t, Config{IstioDistribution: other, DefaultHub: "gcr.io/istio-testing"}, actual)
+	assert.Equal(t, actual, GetActiveConfig())
+
+	// no temporary file is left
+	files, err := ioutil.ReadDir(home)
+	require.NoError(t, err)
+	for _, f := range files {
+		assert.NotContains(t, f.Name(), ".tmp")
+	}
+}
+
+func TestSetIstioVersionOverride(t *testing.T) {
+	GlobalConfigMux.Lock()
+	defer GlobalConfigMux.Unlock()
+	home := t.TempDir()
+	currentConfig = Config{}
+
+	global := &manifest.IstioDistribution{Version: "1.8.1", Flavor: manifest.IstioDistributionFlavorTetrate}
+	require.NoError(t, SetIstioVersion(home, global))
+
+	pinned := &manifest.IstioDistribution{Version: "1.18.2", Flavor: manifest.IstioDistributionFlavorTetrate}
+	SetIstioVersionOverride(pinned, "/work/.getmesh-version")
+	defer SetIstioVersionOverride(nil, "")
+	assert.Equal(t, pinned, GetActiveConfig().IstioDistribution)
+	assert.Equal(t, "/work/.getmesh-version", GetIstioVersionOverrideSource())
+
+	// the override is never written into config.json
+	require.NoError(t, SetDefaultHub(home, "gcr.io/istio-testing"))
+	b, err := ioutil.ReadFile(getConfigPath(home))
+	require.NoError(t, err)
+	var actual Config
+	require.NoError(t, json.Unmarshal(b, &actual))
+	assert.Equal(t, global, actual.IstioDistribution)
+
+	SetIstioVersionOverride(nil, "")
+	assert.Equal(t, global, GetActiveConfig().IstioDistribution)
+	assert.Empty(t, GetIstioVersionOverrideSource())
+}
+
 func Test_getConfigPath(t *testing.T) {
 	home := "this_is_home"
 	assert.Equal(t, filepath.Join(home, "config.json"), getConfigPath(home))
@@ -0,0 +1,54 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package getmesh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/tetratelabs/getmesh/internal/util/filelock"
+)
+
+const (
+	// held exclusively while the installed distributions are changed, i.e. during fetch, switch and prune,
+	// and shared while istioctl is started so that it is never replaced in the middle
+	homeLockName = ".lock"
+	// held while config.json is rewritten. This is separate from the home lock
+	// since the config is updated while the home lock is held, e.g. fetch activates the fetched istioctl.
+	configLockName = ".config.lock"
+)
+
+// LockHome acquires the exclusive lock of the getmesh home directory shared among getmesh processes,
+// blocking until the other process releases it. The configuration is reloaded after the lock is acquired
+// so that the changes made by the other process are visible. The returned func releases the lock.
+func LockHome(homedir string) (func(), error) {
+	unlock, err := filelock.Lock(filepath.Join(homedir, homeLockName))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadConfig(homedir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// RLockHome acquires the shared lock of the getmesh home directory, blocking while the installed
+// distributions are changed by the other process. The configuration is not reloaded. The returned func releases the lock.
+func RLockHome(homedir string) (func(), error) {
+	return filelock.RLock(filepath.Join(homedir, homeLockName))
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package getmesh
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+)
+
+func TestLockHome(t *testing.T) {
+	GlobalConfigMux.Lock()
+	defer GlobalConfigMux.Unlock()
+	home := t.TempDir()
+	currentConfig = Config{}
+
+	unlock, err := LockHome(home)
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		// flock conflicts among the open files even in the same process
+		unlock, err := LockHome(home)
+		require.NoError(t, err)
+		close(acquired)
+		unlock()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the lock must not be acquired while another one holds it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the configuration updated while waiting for the lock must be visible
+	d := &manifest.IstioDistribution{Version: "1.9.0", Flavor: manifest.IstioDistributionFlavorIstio}
+	raw, err := json.Marshal(Config{IstioDistribution: d})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(getConfigPath(home), raw, 0644))
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock must be acquired after released")
+	}
+	require.Equal(t, d, GetActiveConfig().IstioDistribution)
+}
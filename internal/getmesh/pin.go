@@ -0,0 +1,81 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package getmesh
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+)
+
+// PinFileName is the name of the file pinning the istioctl version for the directory tree,
+// containing a distribution name such as "1.18.2-tetrate-v0". Lines starting with "#" are ignored.
+const PinFileName = ".getmesh-version"
+
+// FindPinnedVersion looks for the pin file from dir upwards, and returns the pinned distribution with the path to the file.
+// It returns nil if no pin file is found.
+func FindPinnedVersion(dir string) (*manifest.IstioDistribution, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for {
+		path := filepath.Join(dir, PinFileName)
+		raw, err := ioutil.ReadFile(path)
+		if err == nil {
+			d, err := parsePinFile(raw)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid %s: %v", path, err)
+			}
+			return d, path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("error reading %s: %v", path, err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, "", nil
+		}
+		dir = parent
+	}
+}
+
+func parsePinFile(raw []byte) (*manifest.IstioDistribution, error) {
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return ParseDistributionName(line)
+	}
+	return nil, errors.New("no distribution name is found")
+}
+
+// ParseDistributionName parses the full distribution name such as "1.18.2-tetrate-v0" into the distribution.
+func ParseDistributionName(in string) (*manifest.IstioDistribution, error) {
+	d, err := manifest.IstioDistributionFromString(in)
+	if err != nil {
+		return nil, err
+	} else if len(d.Flavor) == 0 || len(d.Variant) != 0 {
+		return nil, fmt.Errorf("%s is not a distribution name such as 1.18.2-tetrate-v0", in)
+	}
+	return d, nil
+}
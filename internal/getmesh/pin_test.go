@@ -0,0 +1,77 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package getmesh
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+)
+
+func TestFindPinnedVersion(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	nested := filepath.Join(project, "charts", "istio")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+
+	t.Run("not found", func(t *testing.T) {
+		d, path, err := FindPinnedVersion(nested)
+		require.NoError(t, err)
+		require.Nil(t, d)
+		require.Empty(t, path)
+	})
+
+	t.Run("found in the ancestor", func(t *testing.T) {
+		pin := filepath.Join(project, PinFileName)
+		require.NoError(t, ioutil.WriteFile(pin, []byte("# istio 1.18 for this repository\n1.18.2-tetrate-v0\n"), 0644))
+		defer os.Remove(pin)
+
+		d, path, err := FindPinnedVersion(nested)
+		require.NoError(t, err)
+		require.Equal(t, &manifest.IstioDistribution{
+			Version: "1.18.2", Flavor: manifest.IstioDistributionFlavorTetrate, FlavorVersion: 0,
+		}, d)
+		require.Equal(t, pin, path)
+	})
+
+	t.Run("nearest one", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(project, PinFileName), []byte("1.18.2-tetrate-v0"), 0644))
+		defer os.Remove(filepath.Join(project, PinFileName))
+		pin := filepath.Join(nested, PinFileName)
+		require.NoError(t, ioutil.WriteFile(pin, []byte("  1.17.5-istio-v0  "), 0644))
+		defer os.Remove(pin)
+
+		d, path, err := FindPinnedVersion(nested)
+		require.NoError(t, err)
+		require.Equal(t, "1.17.5-istio-v0", d.String())
+		require.Equal(t, pin, path)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, content := range []string{"", "# empty\n", "1.18.2", "1.18.2-tetrate-v0-distroless"} {
+			pin := filepath.Join(project, PinFileName)
+			require.NoError(t, ioutil.WriteFile(pin, []byte(content), 0644))
+			_, _, err := FindPinnedVersion(nested)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), pin)
+			require.NoError(t, os.Remove(pin))
+		}
+	})
+}
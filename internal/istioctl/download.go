@@ -0,0 +1,253 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/filelock"
+	"github.com/tetratelabs/getmesh/internal/util/httpclient"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+// the directory under the getmesh home where downloads and installations are staged.
+// This must be in the same file system as the installations so that they are renamed atomically.
+const tmpDirSuffix = "tmp"
+
+func getTmpDir(homeDir string) string {
+	return filepath.Join(homeDir, tmpDirSuffix)
+}
+
+// the partial file is keyed by the url so that the retry of the same fetch resumes it
+func getDownloadPath(homeDir, url string) string {
+	key := sha256.Sum256([]byte(url))
+	return filepath.Join(getTmpDir(homeDir), "downloads", hex.EncodeToString(key[:8])+".tar.gz.part")
+}
+
+// DownloadArchive downloads the release archive of the target for the given platform into outputDir,
+// verifying it against the manifest where the checksum is published. The distribution is not installed.
+func DownloadArchive(homeDir string, target *manifest.IstioDistribution, ms *manifest.Manifest,
+	goos, goarch, outputDir string) (string, error) {
+	var found *manifest.IstioDistribution
+	for _, m := range ms.IstioDistributions {
+		if m.Equal(target) {
+			found = m
+			break
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("manifest not found for istioctl %s."+
+			" Please check the supported istio versions and flavors by `getmesh list`",
+			target.String())
+	}
+
+	url, err := resolveURL(found, ms, goos, goarch)
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := downloadArchive(homeDir, url, fmt.Sprintf("%s (%s/%s)", target.String(), goos, goarch),
+		found.GetArtifact(goos, goarch))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive)
+
+	// keep the name of the release archive if possible, so that it is recognizable
+	name := path.Base(url)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	if !strings.HasSuffix(name, ".tar.gz") {
+		name = fmt.Sprintf("istio-%s-%s-%s.tar.gz", target.String(), goos, goarch)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(outputDir, name)
+	if err := moveFile(archive, dst); err != nil {
+		return "", fmt.Errorf("error writing %s: %v", dst, err)
+	}
+	return dst, nil
+}
+
+// move the file, copying it if the destination is on another file system
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".part"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// download the archive into the partial file under the getmesh home, resuming the previous attempt if any,
+// then verify it against the artifact in the manifest
+func downloadArchive(homeDir, url, label string, artifact *manifest.Artifact) (string, error) {
+	path := getDownloadPath(homeDir, url)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	// the partial file is shared with the other downloads of the same url, e.g. by another getmesh process.
+	// Only they are serialized, and the home directory is not locked during the download.
+	// The lock file is removed on release so that one is not left for every url ever downloaded.
+	unlock, err := filelock.LockRemovable(path + ".lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if err := downloadWithResume(url, path, label); err != nil {
+		return "", err
+	}
+
+	if artifact == nil || len(artifact.SHA256) == 0 {
+		logger.Warnf("no checksum is published for %s: skipping verification\n", url)
+		return claimDownload(path)
+	}
+
+	size, sum, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := artifact.Verify(size, sum); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("refusing to install %s: %w", url, err)
+	}
+	return claimDownload(path)
+}
+
+// move the downloaded file to the one owned by the caller, so that the next download of the same url
+// never resumes or truncates it while it is being installed
+func claimDownload(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "archive-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+
+	if err := os.Rename(path, f.Name()); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func downloadWithResume(url, path, label string) error {
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request for %s: %v", url, err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := httpclient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		flag |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		flag |= os.O_TRUNC
+		offset = 0
+	case offset > 0 && (resp.StatusCode == http.StatusRequestedRangeNotSatisfiable ||
+		resp.StatusCode == http.StatusPartialContent):
+		// the partial file does not match the remote one anymore, so start over
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return downloadWithResume(url, path, label)
+	default:
+		return fmt.Errorf("error downloading %s: unexpected status %s", url, resp.Status)
+	}
+
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = f
+	if resp.ContentLength >= 0 {
+		if p := newProgressBar(label, offset, offset+resp.ContentLength); p != nil {
+			w = io.MultiWriter(f, p)
+			defer p.done()
+		}
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %w. Please retry to resume the download", url, err)
+	}
+	return nil
+}
+
+func hashFile(path string) (int64, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return size, h.Sum(nil), nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+)
+
+func Test_downloadArchive(t *testing.T) {
+	archive := newIstioArchive(t, "1.10.3", []byte("istioctl"))
+	sum := sha256.Sum256(archive)
+	artifact := &manifest.Artifact{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(archive))}
+
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		switch r.URL.Path {
+		case "/unavailable.tar.gz":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.ServeContent(w, r, "istio.tar.gz", time.Time{}, bytes.NewReader(archive))
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("resume", func(t *testing.T) {
+		ranges = nil
+		dir := t.TempDir()
+		url := ts.URL + "/ok.tar.gz"
+		part := getDownloadPath(dir, url)
+		require.NoError(t, os.MkdirAll(filepath.Dir(part), 0755))
+		require.NoError(t, os.WriteFile(part, archive[:len(archive)/2], 0644))
+
+		actual, err := downloadArchive(dir, url, "1.10.3-tetrate-v0", artifact)
+		require.NoError(t, err)
+		require.Equal(t, []string{fmt.Sprintf("bytes=%d-", len(archive)/2)}, ranges)
+		// handed over to the caller so that the next download starts over
+		require.NotEqual(t, part, actual)
+		_, err = os.Stat(part)
+		require.True(t, os.IsNotExist(err))
+
+		raw, err := os.ReadFile(actual)
+		require.NoError(t, err)
+		require.Equal(t, archive, raw)
+	})
+
+	t.Run("restart the stale partial file", func(t *testing.T) {
+		ranges = nil
+		dir := t.TempDir()
+		url := ts.URL + "/ok.tar.gz"
+		part := getDownloadPath(dir, url)
+		require.NoError(t, os.MkdirAll(filepath.Dir(part), 0755))
+		// larger than the remote one so that the range is not satisfiable
+		require.NoError(t, os.WriteFile(part, append(archive, archive...), 0644))
+
+		actual, err := downloadArchive(dir, url, "1.10.3-tetrate-v0", artifact)
+		require.NoError(t, err)
+		require.Len(t, ranges, 2)
+		require.Equal(t, "", ranges[1])
+
+		raw, err := os.ReadFile(actual)
+		require.NoError(t, err)
+		require.Equal(t, archive, raw)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		dir := t.TempDir()
+		url := ts.URL + "/ok.tar.gz"
+		_, err := downloadArchive(dir, url, "1.10.3-tetrate-v0", &manifest.Artifact{SHA256: hex.EncodeToString(make([]byte, 32))})
+		require.Error(t, err)
+		// the corrupted file must not be resumed
+		_, err = os.Stat(getDownloadPath(dir, url))
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		_, err := downloadArchive(t.TempDir(), ts.URL+"/unavailable.tar.gz", "1.10.3-tetrate-v0", artifact)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "503 Service Unavailable")
+	})
+}
+
+func TestDownloadArchive(t *testing.T) {
+	archive := newIstioArchive(t, "1.10.3", []byte("istioctl"))
+	sum := sha256.Sum256(archive)
+
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write(archive)
+	}))
+	defer ts.Close()
+
+	ms := &manifest.Manifest{
+		IstioDistributions: []*manifest.IstioDistribution{
+			{Version: "1.10.3", Flavor: "tetrate", Artifacts: []*manifest.Artifact{
+				{OS: "darwin", Arch: "arm64", SHA256: hex.EncodeToString(sum[:])},
+				{OS: "linux", Arch: "arm64", URL: ts.URL + "/download?platform=linux-arm64"},
+				{OS: "linux", Arch: "amd64", SHA256: hex.EncodeToString(make([]byte, sha256.Size))},
+			}},
+		},
+		ArtifactURLTemplate: ts.URL + "/istio-{{.Distribution}}-{{.OS}}-{{.Arch}}.tar.gz",
+	}
+	d := &manifest.IstioDistribution{Version: "1.10.3", Flavor: "tetrate"}
+
+	t.Run("verified", func(t *testing.T) {
+		home, out := t.TempDir(), t.TempDir()
+		actual, err := DownloadArchive(home, d, ms, "darwin", "arm64", filepath.Join(out, "bundle"))
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(out, "bundle", "istio-1.10.3-tetrate-v0-darwin-arm64.tar.gz"), actual)
+		require.Equal(t, "/istio-1.10.3-tetrate-v0-darwin-arm64.tar.gz", paths[len(paths)-1])
+
+		raw, err := os.ReadFile(actual)
+		require.NoError(t, err)
+		require.Equal(t, archive, raw)
+
+		// not installed
+		require.Error(t, checkExist(home, d))
+		require.NoFileExists(t, getDownloadPath(home, ts.URL+paths[len(paths)-1]))
+	})
+
+	t.Run("name not in url", func(t *testing.T) {
+		actual, err := DownloadArchive(t.TempDir(), d, ms, "linux", "arm64", t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, "istio-1.10.3-tetrate-v0-linux-arm64.tar.gz", filepath.Base(actual))
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		out := t.TempDir()
+		_, err := DownloadArchive(t.TempDir(), d, ms, "linux", "amd64", out)
+		require.Error(t, err)
+		entries, err := os.ReadDir(out)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("not in manifest", func(t *testing.T) {
+		_, err := DownloadArchive(t.TempDir(), &manifest.IstioDistribution{Version: "1.10.4", Flavor: "tetrate"},
+			ms, "darwin", "arm64", t.TempDir())
+		require.Error(t, err)
+	})
+}
+
+func Test_progressBar(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := &progressBar{w: buf, label: "1.10.3-tetrate-v0", current: 512 * 1024, total: 2 * 1024 * 1024}
+	_, err := p.Write(make([]byte, 512*1024))
+	require.NoError(t, err)
+	require.Equal(t, "\r1.10.3-tetrate-v0 [===============               ]  50% 1.0 MiB/2.0 MiB", buf.String())
+
+	buf.Reset()
+	p.total = 0
+	p.done()
+	require.Equal(t, "\r1.10.3-tetrate-v0 1.0 MiB\n", buf.String())
+}
+
+func Test_formatBytes(t *testing.T) {
+	for in, exp := range map[int64]string{
+		0:                "0 B",
+		1023:             "1023 B",
+		1024:             "1.0 KiB",
+		27 * 1024 * 1024: "27.0 MiB",
+		3 << 40:          "3.0 TiB",
+	} {
+		require.Equal(t, exp, formatBytes(in))
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+// Import installs the istioctl in the local release archive as the target distribution, in the same way as Fetch.
+// The archive is verified against the checksum in the manifest if the manifest is given and publishes it.
+func Import(homeDir, archive string, target *manifest.IstioDistribution, ms *manifest.Manifest) error {
+	if err := checkExist(homeDir, target); err == nil {
+		logger.Infof("%s already fetched: import skipped\n", target.String())
+		return nil
+	}
+
+	var found *manifest.IstioDistribution
+	var artifact *manifest.Artifact
+	if ms != nil {
+		for _, m := range ms.IstioDistributions {
+			if m.Equal(target) {
+				found = m
+				artifact = m.GetArtifact(runtime.GOOS, runtime.GOARCH)
+				break
+			}
+		}
+	}
+
+	if ms == nil {
+		// the caller has warned that no manifest is available
+	} else if artifact == nil || len(artifact.SHA256) == 0 {
+		logger.Warnf("no checksum is published for %s: skipping verification\n", target.String())
+	} else {
+		size, sum, err := hashFile(archive)
+		if err != nil {
+			return err
+		}
+		if err := artifact.Verify(size, sum); err != nil {
+			return fmt.Errorf("refusing to import %s: %w", archive, err)
+		}
+	}
+	abs, err := filepath.Abs(archive)
+	if err != nil {
+		return err
+	}
+	if err := installArchive(homeDir, target, archive, newReceipt(target, found, ReceiptSourceImport, abs), true); err != nil {
+		return err
+	}
+	logger.Infof("%s imported from %s\n", target.String(), archive)
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+)
+
+func TestImport(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	raw := newIstioArchive(t, "1.18.2", []byte("istioctl"))
+	archive := filepath.Join(t.TempDir(), "istio.tar.gz")
+	require.NoError(t, os.WriteFile(archive, raw, 0644))
+	sum := sha256.Sum256(raw)
+
+	d := &manifest.IstioDistribution{Version: "1.18.2", Flavor: "tetrate"}
+	newManifest := func(sha256 string) *manifest.Manifest {
+		return &manifest.Manifest{IstioDistributions: []*manifest.IstioDistribution{
+			{Version: "1.18.2", Flavor: "tetrate", Artifacts: []*manifest.Artifact{
+				{OS: runtime.GOOS, Arch: runtime.GOARCH, SHA256: sha256},
+			}},
+		}}
+	}
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		err := Import(dir, archive, d, newManifest(hex.EncodeToString(make([]byte, sha256.Size))))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "sha256 checksum mismatch")
+		require.Error(t, checkExist(dir, d))
+	})
+
+	for _, c := range []struct {
+		name string
+		ms   *manifest.Manifest
+	}{
+		{name: "verified", ms: newManifest(hex.EncodeToString(sum[:]))},
+		{name: "without manifest"},
+		{name: "not in manifest", ms: &manifest.Manifest{}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+			require.NoError(t, Import(dir, archive, d, c.ms))
+
+			actual, err := os.ReadFile(GetIstioctlPath(dir, d))
+			require.NoError(t, err)
+			require.Equal(t, "istioctl", string(actual))
+			// activated as the first distribution
+			require.Equal(t, d, getmesh.GetActiveConfig().IstioDistribution)
+		})
+	}
+
+	t.Run("not an archive", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		require.Error(t, Import(dir, filepath.Join(dir, "not-exist.tar.gz"), d, nil))
+	})
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+// InstalledDistribution is the distribution fetched into the getmesh home.
+type InstalledDistribution struct {
+	Name          string `json:"name" yaml:"name"`
+	Version       string `json:"version" yaml:"version"`
+	Flavor        string `json:"flavor" yaml:"flavor"`
+	FlavorVersion int64  `json:"flavor_version" yaml:"flavor_version"`
+	Active        bool   `json:"active" yaml:"active"`
+	// Path is the path to the istioctl binary
+	Path      string    `json:"path" yaml:"path"`
+	SizeBytes int64     `json:"size_bytes" yaml:"size_bytes"`
+	FetchedAt time.Time `json:"fetched_at" yaml:"fetched_at"`
+	// Source and URL are recorded in the receipt, and empty for the distributions fetched by older getmesh
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	URL    string `json:"url,omitempty" yaml:"url,omitempty"`
+	// Unmanaged is true if the istioctl is registered by "getmesh link" instead of being fetched
+	Unmanaged bool `json:"unmanaged" yaml:"unmanaged"`
+	// InManifest is nil when the manifest is not available or the distribution is unmanaged
+	InManifest *bool  `json:"in_manifest" yaml:"in_manifest"`
+	EndOfLife  string `json:"end_of_life,omitempty" yaml:"end_of_life,omitempty"`
+
+	receipt *Receipt
+}
+
+// GetInstalledDistributions returns the fetched distributions, ignoring the directories which are not distributions.
+// The manifest is used to tell whether each of them is still supported, and can be nil if not available.
+func GetInstalledDistributions(homeDir string, ms *manifest.Manifest) ([]*InstalledDistribution, error) {
+	curr := getmesh.GetActiveConfig().IstioDistribution
+	istioDir := filepath.Join(homeDir, istioDirSuffix)
+	ditros, err := ioutil.ReadDir(istioDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading directory %s: %v", istioDir, err)
+	}
+
+	ret := make([]*InstalledDistribution, 0, len(ditros))
+	for _, dist := range ditros {
+		if !dist.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(istioDir, dist.Name())
+		r, err := readReceipt(dir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Warnf("ignoring the receipt of %s: %v\n", dist.Name(), err)
+		}
+
+		var d *manifest.IstioDistribution
+		if r != nil {
+			d = r.Distribution()
+		} else if d, err = manifest.IstioDistributionFromString(dist.Name()); err != nil {
+			// not a distribution
+			continue
+		}
+		if d.String() != dist.Name() {
+			continue
+		}
+
+		size, err := dirSize(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		in := &InstalledDistribution{
+			Name:          d.String(),
+			Version:       d.Version,
+			Flavor:        d.Flavor,
+			FlavorVersion: d.FlavorVersion,
+			Active:        curr != nil && curr.Equal(d),
+			Path:          GetIstioctlPath(homeDir, d),
+			SizeBytes:     size,
+			FetchedAt:     dist.ModTime(),
+			receipt:       r,
+		}
+		if r != nil {
+			in.FetchedAt, in.Source, in.URL = r.FetchedAt, r.Source, r.URL
+			in.Unmanaged = r.Source == ReceiptSourceLink
+			in.EndOfLife = r.EndOfLife
+		} else {
+			in.Unmanaged = IsUnmanaged(homeDir, d)
+		}
+
+		if ms != nil && !in.Unmanaged {
+			var found bool
+			for _, m := range ms.IstioDistributions {
+				if m.Equal(d) {
+					found = true
+					break
+				}
+			}
+			in.InManifest = &found
+			in.EndOfLife = ms.GetEndOfLife(d.Version)
+		}
+		ret = append(ret, in)
+	}
+
+	// in the version order rather than the lexical order of the directories, e.g. 1.9.x precedes 1.18.x
+	sort.SliceStable(ret, func(i, j int) bool {
+		c, err := ret[i].distribution().Compare(ret[j].distribution())
+		if err != nil {
+			return ret[i].Name < ret[j].Name
+		}
+		return c < 0
+	})
+	return ret, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var ret int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			ret += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error calculating the size of %s: %v", dir, err)
+	}
+	return ret, nil
+}
+
+// PrintInstalledDistributions prints the distributions in the format, "table", "json" or "yaml".
+func PrintInstalledDistributions(ds []*InstalledDistribution, format string) error {
+	switch format {
+	case "json":
+		raw, err := json.MarshalIndent(ds, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling distributions: %v", err)
+		}
+		logger.Outputf("%s\n", raw)
+	case "yaml":
+		raw, err := yaml.Marshal(ds)
+		if err != nil {
+			return fmt.Errorf("error marshaling distributions: %v", err)
+		}
+		logger.Outputf("%s", raw)
+	case "table":
+		if len(ds) == 0 {
+			logger.Infof("No Istioctl installed yet\n")
+			return nil
+		}
+
+		data := make([][]string, len(ds))
+		for i, d := range ds {
+			name := d.Name
+			if d.Active {
+				name = "*" + name
+			}
+			inManifest := "unknown"
+			if d.Unmanaged {
+				inManifest = "unmanaged"
+			} else if d.InManifest != nil {
+				inManifest = strconv.FormatBool(*d.InManifest)
+			}
+			data[i] = []string{name, d.Version, d.Flavor, strconv.Itoa(int(d.FlavorVersion)),
+				inManifest, d.EndOfLife, formatBytes(d.SizeBytes), d.FetchedAt.Format(time.RFC3339), d.Path}
+		}
+
+		table := tablewriter.NewWriter(logger.GetWriter())
+		table.SetHeader([]string{"NAME", "ISTIO VERSION", "FLAVOR", "FLAVOR VERSION",
+			"IN MANIFEST", "END OF LIFE", "SIZE", "FETCHED AT", "PATH"})
+		util.FlushTable(table, data)
+	default:
+		return fmt.Errorf("unsupported output format %s: must be one of table, json or yaml", format)
+	}
+	return nil
+}
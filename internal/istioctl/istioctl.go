@@ -21,12 +21,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
-	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/tetratelabs/getmesh/internal/getmesh"
 	"github.com/tetratelabs/getmesh/internal/manifest"
@@ -42,6 +43,9 @@ const IstioVersionNoPodRunningMsg = "no running Istio pods in \"istio-system\""
 var (
 	istioDirSuffix     = "istio"
 	istioctlPathFormat = filepath.Join(istioDirSuffix, "%s/bin/istioctl")
+
+	// replaced in the tests
+	inTerminalForeground = isTerminalForeground
 )
 
 func GetIstioctlPath(homeDir string, distribution *manifest.IstioDistribution) string {
@@ -69,34 +73,6 @@ func GetFetchedVersions(homedir string) ([]*manifest.IstioDistribution, error) {
 	return ret, nil
 }
 
-func PrintFetchedVersions(homeDir string) error {
-	curr, _ := GetCurrentExecutable(homeDir)
-	istioDir := filepath.Join(homeDir, istioDirSuffix)
-	ditros, err := ioutil.ReadDir(istioDir)
-	if err != nil {
-		return fmt.Errorf("error reading directory %s: %v", istioDir, err)
-	}
-
-	if len(ditros) == 0 {
-		logger.Infof("No Istioctl installed yet")
-		return nil
-	}
-
-	for _, dist := range ditros {
-		if !dist.IsDir() {
-			continue
-		}
-
-		name := dist.Name()
-		if curr != nil && strings.Contains(name, curr.String()) {
-			logger.Infof(name + " (Active)\n")
-		} else {
-			logger.Infof(name + "\n")
-		}
-	}
-	return nil
-}
-
 func removeAll(homeDir string, current *manifest.IstioDistribution) error {
 	istioDir := filepath.Join(homeDir, istioDirSuffix)
 	ditros, err := ioutil.ReadDir(istioDir)
@@ -176,12 +152,30 @@ func Switch(homeDir string, distribution *manifest.IstioDistribution) error {
 	return getmesh.SetIstioVersion(homeDir, distribution)
 }
 
+// ExitError is returned when istioctl exits with the non-zero code.
+type ExitError struct {
+	// Code is the exit code of istioctl, or 128 + the signal number if it is killed by a signal as shells do
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
 // getmesh istioctl
 func Exec(homeDir string, args []string) error {
 	return ExecWithWriters(homeDir, args, nil, nil)
 }
 
 func ExecWithWriters(homeDir string, args []string, stdout, stderr io.Writer) error {
+	// the installation is replaced by the two renames under the exclusive lock,
+	// so istioctl is looked up and started under the shared one
+	unlock, err := getmesh.RLockHome(homeDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	conf := getmesh.GetActiveConfig()
 	if err := checkExist(homeDir, conf.IstioDistribution); err != nil {
 		return err
@@ -201,14 +195,57 @@ func ExecWithWriters(homeDir string, args []string, stdout, stderr io.Writer) er
 		cmd.Stderr = os.Stderr
 	}
 	cmd.Stdin = os.Stdin
-	return cmd.Run()
+
+	// forward the signals to istioctl so that it can shut down gracefully, instead of getmesh being killed first.
+	// SIGINT is not forwarded only while getmesh is in the foreground of its terminal, where Ctrl-C is delivered
+	// to istioctl in the same process group by the terminal, and a second one could abort its graceful cancellation.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// the running istioctl is not affected by the replacement. The deferred unlock is no-op after this
+	unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				if sig != syscall.SIGINT || !inTerminalForeground() {
+					_ = cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = 128 + int(status.Signal())
+		}
+		return &ExitError{Code: code}
+	}
+	return err
 }
 
+// Fetch fetches the target unless it is already fetched, and activates it when no istioctl is active.
 func Fetch(homeDir string, target *manifest.IstioDistribution, ms *manifest.Manifest) error {
-	var found bool
+	return fetch(homeDir, target, ms, true)
+}
+
+func fetch(homeDir string, target *manifest.IstioDistribution, ms *manifest.Manifest, activate bool) error {
+	var found *manifest.IstioDistribution
 	for _, m := range ms.IstioDistributions {
-		found = m.Equal(target)
-		if found {
+		if m.Equal(target) {
+			found = m
 			target.ReleaseNotes = m.ReleaseNotes
 			break
 		}
@@ -218,66 +255,208 @@ func Fetch(homeDir string, target *manifest.IstioDistribution, ms *manifest.Mani
 		logger.Infof("%s already fetched: download skipped\n", target.String())
 		return nil
 	}
+	return fetchFromManifest(homeDir, target, found, ms, activate)
+}
 
-	if !found {
+// Refetch fetches the target again regardless of the existing installation, which is replaced once the download succeeds.
+func Refetch(homeDir string, target *manifest.IstioDistribution, ms *manifest.Manifest) error {
+	var found *manifest.IstioDistribution
+	for _, m := range ms.IstioDistributions {
+		if m.Equal(target) {
+			found = m
+			break
+		}
+	}
+	return fetchFromManifest(homeDir, target, found, ms, true)
+}
+
+func fetchFromManifest(homeDir string, target, found *manifest.IstioDistribution, ms *manifest.Manifest, activate bool) error {
+	if found == nil {
 		return fmt.Errorf("manifest not found for istioctl %s."+
 			" Please check the supported istio versions and flavors by `getmesh list`",
 			target.String())
 	}
 
-	return fetchIstioctl(homeDir, target)
+	url, err := resolveURL(found, ms, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	return fetchIstioctl(homeDir, target, found, url, found.GetArtifact(runtime.GOOS, runtime.GOARCH), activate)
+}
+
+func resolveURL(found *manifest.IstioDistribution, ms *manifest.Manifest, goos, goarch string) (string, error) {
+	url, err := ms.ResolveArtifactURL(found, goos, goarch)
+	if err != nil {
+		return "", err
+	} else if len(url) == 0 {
+		// Construct URL from GOOS,GOARCH unless the manifest declares it
+		url = fetchIstioctlURL(found, goos, goarch)
+	}
+	return url, nil
+}
+
+// FetchAll fetches the targets concurrently with at most parallelism workers,
+// and returns the errors in the same order as the targets. None of them is activated,
+// since which one would be depends on the order in which the fetches finish.
+func FetchAll(homeDir string, targets []*manifest.IstioDistribution, ms *manifest.Manifest, parallelism int) []error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if len(targets) > 1 && parallelism > 1 {
+		// the progress bars of the concurrent downloads would overwrite each other
+		showProgress = false
+		defer func() { showProgress = true }()
+	}
+
+	errs := make([]error, len(targets))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target *manifest.IstioDistribution) {
+			defer func() { <-sem; wg.Done() }()
+			errs[i] = fetch(homeDir, target, ms, false)
+		}(i, target)
+	}
+	wg.Wait()
+	return errs
 }
 
-func fetchIstioctl(homeDir string, targetDistribution *manifest.IstioDistribution) error {
-	// Create dir
-	dir := filepath.Join(homeDir, istioDirSuffix, targetDistribution.String(), "bin")
-	if err := os.MkdirAll(dir, 0755); err != nil {
+// found is the distribution in the manifest, recorded in the receipt
+func fetchIstioctl(homeDir string, targetDistribution, found *manifest.IstioDistribution, url string, artifact *manifest.Artifact, activate bool) error {
+	// Download and verify before touching the installation
+	archive, err := downloadArchive(homeDir, url, targetDistribution.String(), artifact)
+	if err != nil {
 		return err
 	}
+	defer os.Remove(archive)
+	return installArchive(homeDir, targetDistribution, archive, newReceipt(targetDistribution, found, ReceiptSourceFetch, url), activate)
+}
 
-	// Construct URL from GOOS,GOARCH
-	url := fetchIstioctlURL(targetDistribution, runtime.GOOS, runtime.GOARCH)
+// install the istioctl in the archive downloaded or imported, along with the receipt.
+// When activate is true, it is activated if no istioctl is active.
+func installArchive(homeDir string, targetDistribution *manifest.IstioDistribution, archive string, receipt *Receipt, activate bool) error {
+	// Extract into the staging dir, and move it to the installation dir at once
+	// so that the interrupted fetch never leaves a partial installation
+	if err := os.MkdirAll(getTmpDir(homeDir), 0755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(getTmpDir(homeDir), targetDistribution.String()+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
 
-	// Download
-	resp, err := http.Get(url)
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(staging, "bin"), 0755); err != nil {
+		return err
+	}
+	if err := extractIstioctl(archive, filepath.Join(staging, "bin", "istioctl")); err != nil {
+		return fmt.Errorf("error extracting istioctl from %s: %w", receipt.URL, err)
+	}
+	if err := receipt.setChecksums(archive, filepath.Join(staging, "bin", "istioctl")); err != nil {
+		return err
+	}
+	if err := writeReceipt(staging, receipt); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(homeDir, istioDirSuffix, targetDistribution.String())
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+
+	// the home directory is locked only while the installation and the config are changed,
+	// so that the other getmesh commands are not blocked during the download
+	unlock, err := getmesh.LockHome(homeDir)
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("404 not found for %s", url)
+	defer unlock()
+
+	if err := replaceDir(staging, dir); err != nil {
+		return fmt.Errorf("error installing %s: %w", targetDistribution.String(), err)
+	}
+
+	// Set active istioctl to the downloaded one
+	if conf := getmesh.GetActiveConfig(); activate && conf.IstioDistribution == nil {
+		if err := getmesh.SetIstioVersion(homeDir, targetDistribution); err != nil {
+			return fmt.Errorf("error switching to %s: %w", targetDistribution.String(), err)
+		}
+	}
+	return nil
+}
+
+// replaceDir moves src to dst. The existing dst, e.g. the active istioctl refetched by verify,
+// is moved aside until src is in place, and restored if the move fails. This is not atomic,
+// so it must be called under the exclusive home lock, which the readers of dst take shared.
+func replaceDir(src, dst string) error {
+	old := src + ".old"
+	if err := os.Rename(dst, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		if rerr := os.Rename(old, dst); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			return fmt.Errorf("%w (failed to restore %s: %v)", err, dst, rerr)
+		}
+		return err
 	}
+	return os.RemoveAll(old)
+}
 
-	defer resp.Body.Close()
+// extract the istioctl binary in the archive to dst
+func extractIstioctl(archive, dst string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	// Read body
-	gr, _ := gzip.NewReader(resp.Body)
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("invalid gzip archive: %w", err)
+	}
 	defer gr.Close()
 	tr := tar.NewReader(gr)
 
 	for {
 		h, err := tr.Next()
 		if err == io.EOF {
-			break
+			return errors.New("istioctl not found in the archive")
+		} else if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
 		}
 
-		if filepath.Base(h.Name) == "istioctl" {
-			bin, err := io.ReadAll(tr)
-			if err != nil {
-				return err
-			}
-			if err = os.WriteFile(filepath.Join(dir, "istioctl"), bin, 0755); err != nil {
-				return err
-			}
+		if h.Typeflag == tar.TypeReg && filepath.Base(h.Name) == "istioctl" {
+			return writeExecutable(tr, dst)
 		}
 	}
+}
 
-	// Set active istioctl to the downloaded one
-	if conf := getmesh.GetActiveConfig(); conf.IstioDistribution == nil {
-		if err := getmesh.SetIstioVersion(homeDir, targetDistribution); err != nil {
-			return fmt.Errorf("error switching to %s", conf.IstioDistribution.String())
-		}
+// write the executable into a temporary file next to dst then rename it,
+// so that dst never becomes a partially written binary
+func writeExecutable(r io.Reader, dst string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
 	}
-	return nil
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
 }
 
 func fetchIstioctlURL(targetDistribution *manifest.IstioDistribution, runtimeGOOS string, runtimeGOARCH string) string {
@@ -15,11 +15,23 @@
 package istioctl
 
 import (
+	"archive/tar"
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -59,44 +71,141 @@ func TestGetFetchedVersions(t *testing.T) {
 	require.Empty(t, exp)
 }
 
-func TestPrintFetchedVersions(t *testing.T) {
+func TestGetInstalledDistributions(t *testing.T) {
 	getmesh.GlobalConfigMux.Lock()
 	defer getmesh.GlobalConfigMux.Unlock()
 
-	t.Run("ok", func(t *testing.T) {
-		dir := t.TempDir()
+	dir := t.TempDir()
+	d := &manifest.IstioDistribution{
+		Version:       "1.7.3",
+		Flavor:        manifest.IstioDistributionFlavorTetrate,
+		FlavorVersion: 0,
+	}
+	require.NoError(t, getmesh.SetIstioVersion(dir, d))
 
-		d := &manifest.IstioDistribution{
-			Version:       "1.7.3",
+	t.Run("not fetched", func(t *testing.T) {
+		actual, err := GetInstalledDistributions(dir, nil)
+		require.NoError(t, err)
+		require.Empty(t, actual)
+	})
+
+	for _, v := range []string{
+		"20.1.1", "1.2.4", "1.7.3", "1.10.0",
+	} {
+		ctlPath := GetIstioctlPath(dir, &manifest.IstioDistribution{
+			Version:       v,
 			Flavor:        manifest.IstioDistributionFlavorTetrate,
 			FlavorVersion: 0,
+		})
+		require.NoError(t, os.MkdirAll(filepath.Dir(ctlPath), 0755))
+		require.NoError(t, os.WriteFile(ctlPath, []byte("istioctl"), 0755))
+	}
+	// not distributions
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, istioDirSuffix, "backup"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, istioDirSuffix, "1.8.0-tetrate-v0"), nil, 0644))
+
+	t.Run("without manifest", func(t *testing.T) {
+		actual, err := GetInstalledDistributions(dir, nil)
+		require.NoError(t, err)
+		require.Len(t, actual, 4)
+		var names []string
+		for _, a := range actual {
+			require.Nil(t, a.InManifest)
+			require.Empty(t, a.EndOfLife)
+			names = append(names, a.Name)
 		}
+		// in the version order
+		require.Equal(t, []string{"1.2.4-tetrate-v0", "1.7.3-tetrate-v0", "1.10.0-tetrate-v0", "20.1.1-tetrate-v0"}, names)
+	})
 
-		require.NoError(t, getmesh.SetIstioVersion(dir, d))
-		for _, v := range []string{
-			"20.1.1", "1.2.4", "1.7.3",
-		} {
-			ctlPath := GetIstioctlPath(dir, &manifest.IstioDistribution{
-				Version:       v,
-				Flavor:        manifest.IstioDistributionFlavorTetrate,
-				FlavorVersion: 0,
-			})
-			suffix := strings.TrimSuffix(ctlPath, "/istioctl")
-			require.NoError(t, os.MkdirAll(suffix, 0755))
-			f, err := os.Create(ctlPath)
-			require.NoError(t, err)
-			require.NoError(t, f.Close())
+	t.Run("with manifest", func(t *testing.T) {
+		ms := &manifest.Manifest{
+			IstioDistributions: []*manifest.IstioDistribution{
+				{Version: "1.7.3", Flavor: manifest.IstioDistributionFlavorTetrate},
+			},
+			IstioMinorVersionsEOLDates: map[string]string{"1.7": "2021-02-01"},
 		}
+		actual, err := GetInstalledDistributions(dir, ms)
+		require.NoError(t, err)
+		require.Len(t, actual, 4)
+
+		a := actual[1]
+		require.NotZero(t, a.FetchedAt)
+		a.FetchedAt = time.Time{}
+		inManifest := true
+		require.Equal(t, &InstalledDistribution{
+			Name:          "1.7.3-tetrate-v0",
+			Version:       "1.7.3",
+			Flavor:        manifest.IstioDistributionFlavorTetrate,
+			FlavorVersion: 0,
+			Active:        true,
+			Path:          GetIstioctlPath(dir, d),
+			SizeBytes:     int64(len("istioctl")),
+			InManifest:    &inManifest,
+			EndOfLife:     "2021-02-01",
+		}, a)
+
+		require.Equal(t, "1.2.4-tetrate-v0", actual[0].Name)
+		require.False(t, actual[0].Active)
+		require.False(t, *actual[0].InManifest)
+		require.Empty(t, actual[0].EndOfLife)
+	})
+}
 
+func TestPrintInstalledDistributions(t *testing.T) {
+	inManifest := false
+	ds := []*InstalledDistribution{
+		{
+			Name: "1.7.3-tetrate-v0", Version: "1.7.3", Flavor: "tetrate", FlavorVersion: 0,
+			Active: true, Path: "/home/.getmesh/istio/1.7.3-tetrate-v0/bin/istioctl", SizeBytes: 2048,
+			FetchedAt: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), InManifest: &inManifest, EndOfLife: "2021-02-01",
+		},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintInstalledDistributions(ds, "table"))
+		})
+		require.Contains(t, buf.String(), "*1.7.3-tetrate-v0")
+		require.Contains(t, buf.String(), "2.0 KiB")
+		require.Contains(t, buf.String(), "2021-01-02T03:04:05Z")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintInstalledDistributions(ds, "json"))
+		})
+		require.JSONEq(t, `[{
+  "name": "1.7.3-tetrate-v0",
+  "version": "1.7.3",
+  "flavor": "tetrate",
+  "flavor_version": 0,
+  "active": true,
+  "path": "/home/.getmesh/istio/1.7.3-tetrate-v0/bin/istioctl",
+  "size_bytes": 2048,
+  "fetched_at": "2021-01-02T03:04:05Z",
+  "unmanaged": false,
+  "in_manifest": false,
+  "end_of_life": "2021-02-01"
+}]`, buf.String())
+	})
+
+	t.Run("yaml", func(t *testing.T) {
 		buf := logger.ExecuteWithLock(func() {
-			require.NoError(t, PrintFetchedVersions(dir))
+			require.NoError(t, PrintInstalledDistributions(ds, "yaml"))
 		})
-		exp := `1.2.4-tetrate-v0
-1.7.3-tetrate-v0 (Active)
-20.1.1-tetrate-v0
-`
-		require.Equal(t, exp, buf.String())
+		require.Contains(t, buf.String(), "- name: 1.7.3-tetrate-v0\n")
+		require.Contains(t, buf.String(), "  in_manifest: false\n")
 	})
+
+	t.Run("empty", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintInstalledDistributions([]*InstalledDistribution{}, "json"))
+		})
+		require.Equal(t, "[]\n", buf.String())
+	})
+
+	require.Error(t, PrintInstalledDistributions(ds, "csv"))
 }
 
 func TestGetCurrentExecutable(t *testing.T) {
@@ -351,6 +460,137 @@ func TestExec(t *testing.T) {
 	require.Equal(t, buf.String(), "istioctl")
 }
 
+func TestExec_exitCode(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	dir := t.TempDir()
+	d := &manifest.IstioDistribution{
+		Version:       "0.0.1",
+		Flavor:        manifest.IstioDistributionFlavorTetrate,
+		FlavorVersion: 0,
+	}
+	require.NoError(t, getmesh.SetIstioVersion(dir, d))
+	ctlPath := GetIstioctlPath(dir, d)
+	require.NoError(t, os.MkdirAll(filepath.Dir(ctlPath), 0755))
+	require.NoError(t, os.WriteFile(ctlPath, []byte(`#!/bin/sh
+case "$1" in
+  analyze) echo "Error [IST0101]"; exit 79 ;;
+  killed) kill -KILL $$ ;;
+  wait) trap 'echo terminated; exit 42' TERM; trap 'echo interrupted; exit 43' INT; echo ready; while :; do sleep 0.1; done ;;
+esac
+`), 0755))
+
+	t.Run("exit code", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := ExecWithWriters(dir, []string{"analyze"}, buf, nil)
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 79, exitErr.Code)
+		require.Equal(t, "exit status 79", err.Error())
+		require.Equal(t, "Error [IST0101]\n", buf.String())
+	})
+
+	t.Run("killed", func(t *testing.T) {
+		err := ExecWithWriters(dir, []string{"killed"}, new(bytes.Buffer), nil)
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 128+int(syscall.SIGKILL), exitErr.Code)
+	})
+
+	t.Run("forward signal", func(t *testing.T) {
+		r, w := io.Pipe()
+		go func() {
+			sc := bufio.NewScanner(r)
+			for sc.Scan() {
+				if sc.Text() == "ready" {
+					// getmesh itself survives since the signal is caught while istioctl is running
+					_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+				}
+			}
+		}()
+
+		err := ExecWithWriters(dir, []string{"wait"}, w, nil)
+		w.Close()
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 42, exitErr.Code)
+	})
+
+	t.Run("interrupt", func(t *testing.T) {
+		// e.g. "kill -INT" by CI or a supervisor
+		inTerminalForeground = func() bool { return false }
+		defer func() { inTerminalForeground = isTerminalForeground }()
+
+		r, w := io.Pipe()
+		go func() {
+			sc := bufio.NewScanner(r)
+			for sc.Scan() {
+				if sc.Text() == "ready" {
+					_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+				}
+			}
+		}()
+
+		err := ExecWithWriters(dir, []string{"wait"}, w, nil)
+		w.Close()
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 43, exitErr.Code)
+	})
+
+	t.Run("interrupt in terminal", func(t *testing.T) {
+		inTerminalForeground = func() bool { return true }
+		defer func() { inTerminalForeground = isTerminalForeground }()
+
+		r, w := io.Pipe()
+		go func() {
+			sc := bufio.NewScanner(r)
+			for sc.Scan() {
+				if sc.Text() == "ready" {
+					// the terminal delivers Ctrl-C to istioctl, so getmesh must not send it again
+					_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+					time.Sleep(300 * time.Millisecond)
+					_ = syscall.Kill(os.Getpid(), syscall.SIGHUP)
+				}
+			}
+		}()
+
+		err := ExecWithWriters(dir, []string{"wait"}, w, nil)
+		w.Close()
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		// killed by the forwarded SIGHUP, not interrupted
+		require.Equal(t, 128+int(syscall.SIGHUP), exitErr.Code)
+	})
+
+	t.Run("wait for replacement", func(t *testing.T) {
+		// e.g. the active istioctl is being refetched by another getmesh process
+		unlock, err := getmesh.LockHome(dir)
+		require.NoError(t, err)
+		defer unlock()
+
+		done := make(chan error, 1)
+		go func() { done <- ExecWithWriters(dir, []string{"analyze"}, new(bytes.Buffer), nil) }()
+
+		select {
+		case <-done:
+			t.Fatal("istioctl must not be started while the installation is being replaced")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		unlock()
+		select {
+		case err := <-done:
+			var exitErr *ExitError
+			require.ErrorAs(t, err, &exitErr)
+			require.Equal(t, 79, exitErr.Code)
+		case <-time.After(5 * time.Second):
+			t.Fatal("istioctl must be started after the replacement")
+		}
+	})
+}
+
 func TestFetch(t *testing.T) {
 	dir := t.TempDir()
 	ms := &manifest.Manifest{
@@ -405,6 +645,29 @@ func TestFetch(t *testing.T) {
 		}
 	})
 
+	t.Run("manifest url", func(t *testing.T) {
+		getmesh.GlobalConfigMux.Lock()
+		defer getmesh.GlobalConfigMux.Unlock()
+
+		archive := newIstioArchive(t, "1.10.3", []byte("istioctl"))
+		var requested string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = r.URL.Path
+			_, _ = w.Write(archive)
+		}))
+		defer ts.Close()
+
+		c := &manifest.IstioDistribution{Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrate, FlavorVersion: 0}
+		mirrored := &manifest.Manifest{
+			IstioDistributions:  []*manifest.IstioDistribution{{Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrate}},
+			ArtifactURLTemplate: ts.URL + "/mirror/{{.Distribution}}/{{.OS}}-{{.Arch}}.tar.gz",
+		}
+		mirrorDir := t.TempDir()
+		require.NoError(t, Fetch(mirrorDir, c, mirrored))
+		require.NoError(t, checkExist(mirrorDir, c))
+		require.Equal(t, "/mirror/1.10.3-tetrate-v0/"+runtime.GOOS+"-"+runtime.GOARCH+".tar.gz", requested)
+	})
+
 	t.Run("already exist", func(t *testing.T) {
 		target := &manifest.IstioDistribution{
 			Version:       "111111111111",
@@ -450,6 +713,209 @@ func TestFetch(t *testing.T) {
 	})
 }
 
+// create the gzipped tar archive in the same layout as Istio releases
+func newIstioArchive(t *testing.T, version string, istioctl []byte) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for name, body := range map[string][]byte{
+		"istio-" + version + "/manifest.yaml": []byte("version: " + version),
+		"istio-" + version + "/bin/istioctl":  istioctl,
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(body)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(body)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestFetchAll(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	archive := newIstioArchive(t, "1.10.3", []byte("istioctl"))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "1.7.6") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	defer ts.Close()
+
+	ms := &manifest.Manifest{
+		IstioDistributions: []*manifest.IstioDistribution{
+			{Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrate},
+			{Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrateFIPS},
+			{Version: "1.9.5", Flavor: manifest.IstioDistributionFlavorIstio},
+			{Version: "1.7.6", Flavor: manifest.IstioDistributionFlavorTetrate},
+		},
+		ArtifactURLTemplate: ts.URL + "/{{.Distribution}}.tar.gz",
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+
+	targets := []*manifest.IstioDistribution{
+		{Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrate},
+		{Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrateFIPS},
+		{Version: "1.7.6", Flavor: manifest.IstioDistributionFlavorTetrate},
+		{Version: "1.9.5", Flavor: manifest.IstioDistributionFlavorIstio},
+	}
+	errs := FetchAll(dir, targets, ms, 2)
+	require.Len(t, errs, len(targets))
+	for i, target := range targets {
+		if target.Version == "1.7.6" {
+			require.Error(t, errs[i])
+			require.Error(t, checkExist(dir, target))
+		} else {
+			require.NoError(t, errs[i])
+			require.NoError(t, checkExist(dir, target))
+		}
+	}
+
+	// none of them is activated even without the active one, since it would depend on which fetch finishes first
+	require.Nil(t, getmesh.GetActiveConfig().IstioDistribution)
+	require.True(t, showProgress)
+}
+
+func Test_fetchIstioctl(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	archive := newIstioArchive(t, "1.10.3", []byte("istioctl"))
+	sum := sha256.Sum256(archive)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/truncated.tar.gz":
+			_, _ = w.Write(archive[:len(archive)/2])
+		case "/not-archive.tar.gz":
+			_, _ = w.Write([]byte("<html>not found</html>"))
+		default:
+			_, _ = w.Write(archive)
+		}
+	}))
+	defer ts.Close()
+
+	d := &manifest.IstioDistribution{Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrate, FlavorVersion: 0}
+
+	t.Run("ok", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		require.NoError(t, fetchIstioctl(dir, d, nil, ts.URL+"/ok.tar.gz", &manifest.Artifact{
+			SHA256: hex.EncodeToString(sum[:]), Size: int64(len(archive)),
+		}, true))
+		actual, err := os.ReadFile(GetIstioctlPath(dir, d))
+		require.NoError(t, err)
+		require.Equal(t, "istioctl", string(actual))
+		require.Equal(t, d, getmesh.GetActiveConfig().IstioDistribution)
+
+		// neither the archive nor its lock file is left
+		left, err := os.ReadDir(filepath.Dir(getDownloadPath(dir, ts.URL+"/ok.tar.gz")))
+		require.NoError(t, err)
+		require.Empty(t, left)
+	})
+
+	t.Run("home unlocked during download", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the other getmesh commands can lock the home directory meanwhile
+			locked := make(chan struct{})
+			go func() {
+				if unlock, err := getmesh.LockHome(dir); err == nil {
+					unlock()
+					close(locked)
+				}
+			}()
+			select {
+			case <-locked:
+				_, _ = w.Write(archive)
+			case <-time.After(5 * time.Second):
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer ts.Close()
+
+		require.NoError(t, fetchIstioctl(dir, d, nil, ts.URL+"/ok.tar.gz", nil, true))
+		require.NoError(t, checkExist(dir, d))
+	})
+
+	for _, c := range []struct {
+		name     string
+		url      string
+		artifact *manifest.Artifact
+	}{
+		{
+			name:     "checksum mismatch",
+			url:      ts.URL + "/ok.tar.gz",
+			artifact: &manifest.Artifact{SHA256: strings.Repeat("0", 64)},
+		},
+		{
+			name:     "truncated",
+			url:      ts.URL + "/truncated.tar.gz",
+			artifact: &manifest.Artifact{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(archive))},
+		},
+		{
+			// no checksum published so the archive is not verified
+			name: "invalid archive",
+			url:  ts.URL + "/not-archive.tar.gz",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.Error(t, fetchIstioctl(dir, d, nil, c.url, c.artifact, true))
+			require.Error(t, checkExist(dir, d))
+			// no partial installation is left
+			_, err := os.Stat(filepath.Join(dir, istioDirSuffix, d.String()))
+			require.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func Test_replaceDir(t *testing.T) {
+	t.Run("replace", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "staging"), filepath.Join(dir, "dst")
+		require.NoError(t, os.MkdirAll(src, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "new"), nil, 0644))
+		require.NoError(t, os.MkdirAll(dst, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dst, "old"), nil, 0644))
+
+		require.NoError(t, replaceDir(src, dst))
+		_, err := os.Stat(filepath.Join(dst, "new"))
+		require.NoError(t, err)
+		_, err = os.Stat(filepath.Join(dst, "old"))
+		require.True(t, os.IsNotExist(err))
+		_, err = os.Stat(src + ".old")
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "staging"), filepath.Join(dir, "dst")
+		require.NoError(t, os.MkdirAll(src, 0755))
+		require.NoError(t, replaceDir(src, dst))
+		_, err := os.Stat(dst)
+		require.NoError(t, err)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		dir := t.TempDir()
+		dst := filepath.Join(dir, "dst")
+		require.NoError(t, os.MkdirAll(dst, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dst, "old"), nil, 0644))
+
+		// src does not exist so that the move fails
+		require.Error(t, replaceDir(filepath.Join(dir, "staging"), dst))
+		_, err := os.Stat(filepath.Join(dst, "old"))
+		require.NoError(t, err)
+	})
+}
+
 func TestFetchIstioctlURL(t *testing.T) {
 	istioDistribution := &manifest.IstioDistribution{
 		Version:       "1.7.6",
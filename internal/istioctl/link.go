@@ -0,0 +1,76 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+)
+
+// Link registers the locally built istioctl as the distribution of a custom flavor.
+// The istioctl is symlinked rather than copied so that rebuilding it takes effect, and the distribution is unmanaged:
+// it is never fetched nor checked against the manifest.
+func Link(homeDir, binary string, target *manifest.IstioDistribution) error {
+	if manifest.IsManagedFlavor(target.Flavor) {
+		return fmt.Errorf("%s is the flavor distributed by the manifest: use a custom flavor such as 1.18.2-acme-v0",
+			target.Flavor)
+	}
+
+	binary, err := filepath.Abs(binary)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(binary); err != nil {
+		return fmt.Errorf("error checking %s: %v", binary, err)
+	} else if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a file", binary)
+	}
+
+	path := GetIstioctlPath(homeDir, target)
+	if _, err := os.Lstat(path); err == nil && !IsUnmanaged(homeDir, target) {
+		return fmt.Errorf("%s already exists and is not linked", target.String())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// replace the link at once when linking again to another binary
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(binary, tmp); err != nil {
+		return fmt.Errorf("error linking %s: %v", binary, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error linking %s: %v", binary, err)
+	}
+	return writeReceipt(filepath.Join(homeDir, istioDirSuffix, target.String()),
+		newReceipt(target, nil, ReceiptSourceLink, binary))
+}
+
+// IsUnmanaged returns true if the distribution is registered by Link instead of being fetched.
+func IsUnmanaged(homeDir string, d *manifest.IstioDistribution) bool {
+	info, err := os.Lstat(GetIstioctlPath(homeDir, d))
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// GetLinkedBinary returns the istioctl binary which the unmanaged distribution is linked to.
+func GetLinkedBinary(homeDir string, d *manifest.IstioDistribution) (string, error) {
+	return os.Readlink(GetIstioctlPath(homeDir, d))
+}
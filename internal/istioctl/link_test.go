@@ -0,0 +1,95 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+)
+
+func TestLink(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	dir := t.TempDir()
+	require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+	bin := t.TempDir()
+	for _, name := range []string{"istioctl", "istioctl-v2"} {
+		require.NoError(t, os.WriteFile(filepath.Join(bin, name), []byte(name), 0755))
+	}
+
+	d := &manifest.IstioDistribution{Version: "1.18.2", Flavor: "acme", FlavorVersion: 3}
+	t.Run("ok", func(t *testing.T) {
+		require.NoError(t, Link(dir, filepath.Join(bin, "istioctl"), d))
+		require.True(t, IsUnmanaged(dir, d))
+		require.NoError(t, checkExist(dir, d))
+
+		actual, err := GetLinkedBinary(dir, d)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(bin, "istioctl"), actual)
+
+		// switchable
+		require.NoError(t, Switch(dir, d))
+	})
+
+	t.Run("link again", func(t *testing.T) {
+		require.NoError(t, Link(dir, filepath.Join(bin, "istioctl-v2"), d))
+		actual, err := os.ReadFile(GetIstioctlPath(dir, d))
+		require.NoError(t, err)
+		require.Equal(t, "istioctl-v2", string(actual))
+	})
+
+	t.Run("not checked against manifest", func(t *testing.T) {
+		ms := &manifest.Manifest{IstioMinorVersionsEOLDates: map[string]string{"1.18": "2024-01-01"}}
+		ds, err := GetInstalledDistributions(dir, ms)
+		require.NoError(t, err)
+		require.Len(t, ds, 1)
+		require.True(t, ds[0].Unmanaged)
+		require.True(t, ds[0].Active)
+		require.Nil(t, ds[0].InManifest)
+		require.Empty(t, ds[0].EndOfLife)
+
+		rs, err := Verify(dir, ms)
+		require.NoError(t, err)
+		require.Len(t, rs, 1)
+		require.Equal(t, VerifyStatusUnmanaged, rs[0].Status)
+		require.False(t, rs[0].Broken())
+	})
+
+	t.Run("managed flavor", func(t *testing.T) {
+		err := Link(dir, filepath.Join(bin, "istioctl"), &manifest.IstioDistribution{Version: "1.18.2", Flavor: "tetrate"})
+		require.Error(t, err)
+	})
+
+	t.Run("fetched one", func(t *testing.T) {
+		fetched := &manifest.IstioDistribution{Version: "1.18.2", Flavor: "acme", FlavorVersion: 4}
+		p := GetIstioctlPath(dir, fetched)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, nil, 0755))
+		require.False(t, IsUnmanaged(dir, fetched))
+		require.Error(t, Link(dir, filepath.Join(bin, "istioctl"), fetched))
+	})
+
+	t.Run("no binary", func(t *testing.T) {
+		require.Error(t, Link(dir, filepath.Join(bin, "not-exist"), d))
+		require.Error(t, Link(dir, bin, d))
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressBarWidth    = 30
+	progressBarInterval = 100 * time.Millisecond
+)
+
+var (
+	// the progress bar is rendered only when this is a terminal
+	progressOutput = os.Stderr
+	showProgress   = true
+)
+
+type progressBar struct {
+	w              io.Writer
+	label          string
+	current, total int64
+	lastRendered   time.Time
+}
+
+// returns nil when the progress output is not a terminal
+func newProgressBar(label string, current, total int64) *progressBar {
+	if !showProgress || !term.IsTerminal(int(progressOutput.Fd())) {
+		return nil
+	}
+	return &progressBar{w: progressOutput, label: label, current: current, total: total}
+}
+
+func (p *progressBar) Write(b []byte) (int, error) {
+	p.current += int64(len(b))
+	if now := time.Now(); now.Sub(p.lastRendered) >= progressBarInterval {
+		p.lastRendered = now
+		p.render()
+	}
+	return len(b), nil
+}
+
+func (p *progressBar) render() {
+	if p.total <= 0 {
+		fmt.Fprintf(p.w, "\r%s %s", p.label, formatBytes(p.current))
+		return
+	}
+
+	ratio := float64(p.current) / float64(p.total)
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * progressBarWidth)
+	fmt.Fprintf(p.w, "\r%s [%s%s] %3d%% %s/%s", p.label,
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+		int(ratio*100), formatBytes(p.current), formatBytes(p.total))
+}
+
+func (p *progressBar) done() {
+	p.render()
+	fmt.Fprintln(p.w)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+// PrunePolicy selects the distributions to remove. A distribution is removed when any of the enabled rules matches,
+// and the active one is always kept.
+type PrunePolicy struct {
+	// KeepLatestPatches keeps the newest N distributions in each group of minor version and flavor if positive
+	KeepLatestPatches int
+	// EndOfLife removes the distributions whose minor version is past the end of life
+	EndOfLife bool
+	// NotInManifest removes the distributions which are no longer in the manifest
+	NotInManifest bool
+	// OlderThan removes the distributions fetched before this duration if positive
+	OlderThan time.Duration
+}
+
+// Enabled returns true if any rule is set. The empty policy removes all but the active one.
+func (p *PrunePolicy) Enabled() bool {
+	return p.KeepLatestPatches > 0 || p.EndOfLife || p.NotInManifest || p.OlderThan > 0
+}
+
+// RequiresManifest returns true if the rules need the distributions to be loaded with the manifest.
+func (p *PrunePolicy) RequiresManifest() bool {
+	return p.EndOfLife || p.NotInManifest
+}
+
+// PruneTarget is the distribution to be removed with the reasons.
+type PruneTarget struct {
+	*InstalledDistribution
+	Reasons []string
+}
+
+// SelectPruneTargets returns the distributions to be removed by the policy at the given time.
+func SelectPruneTargets(ds []*InstalledDistribution, p *PrunePolicy, now time.Time) ([]*PruneTarget, error) {
+	reasons := make(map[string][]string, len(ds))
+	add := func(d *InstalledDistribution, reason string) {
+		reasons[d.Name] = append(reasons[d.Name], reason)
+	}
+
+	if !p.Enabled() {
+		for _, d := range ds {
+			add(d, "not active")
+		}
+	}
+
+	if p.KeepLatestPatches > 0 {
+		groups := map[string][]*InstalledDistribution{}
+		for _, d := range ds {
+			g, err := d.distribution().Group()
+			if err != nil {
+				return nil, err
+			}
+			groups[g] = append(groups[g], d)
+		}
+
+		for g, gds := range groups {
+			var sortErr error
+			sort.SliceStable(gds, func(i, j int) bool {
+				ok, err := gds[i].distribution().GreaterThan(gds[j].distribution())
+				if err != nil {
+					sortErr = err
+				}
+				return ok
+			})
+			if sortErr != nil {
+				return nil, sortErr
+			}
+
+			if len(gds) <= p.KeepLatestPatches {
+				continue
+			}
+			for _, d := range gds[p.KeepLatestPatches:] {
+				add(d, fmt.Sprintf("older than the latest %d in %s", p.KeepLatestPatches, g))
+			}
+		}
+	}
+
+	for _, d := range ds {
+		if p.EndOfLife && len(d.EndOfLife) != 0 {
+			eol, err := time.Parse("2006-01-02", d.EndOfLife)
+			if err != nil {
+				return nil, fmt.Errorf("invalid end of life %s of %s: %v", d.EndOfLife, d.Name, err)
+			}
+			if now.After(eol) {
+				add(d, fmt.Sprintf("end of life on %s", d.EndOfLife))
+			}
+		}
+
+		if p.NotInManifest && d.InManifest != nil && !*d.InManifest {
+			add(d, "not in manifest")
+		}
+
+		if p.OlderThan > 0 && now.Sub(d.FetchedAt) > p.OlderThan {
+			add(d, fmt.Sprintf("fetched at %s", d.FetchedAt.Format(time.RFC3339)))
+		}
+	}
+
+	var ret []*PruneTarget
+	for _, d := range ds {
+		rs, ok := reasons[d.Name]
+		if !ok || d.Active {
+			continue
+		}
+		ret = append(ret, &PruneTarget{InstalledDistribution: d, Reasons: rs})
+	}
+	return ret, nil
+}
+
+// Prune removes the targets, or only prints them if dryRun is true, along with the disk space to be freed.
+func Prune(homeDir string, targets []*PruneTarget, dryRun bool) error {
+	if len(targets) == 0 {
+		logger.Infof("nothing to remove\n")
+		return nil
+	}
+
+	var freed int64
+	for _, t := range targets {
+		if dryRun {
+			logger.Infof("would remove %s (%s): %s\n", t.Name, formatBytes(t.SizeBytes), strings.Join(t.Reasons, ", "))
+		} else {
+			if err := os.RemoveAll(filepath.Join(homeDir, istioDirSuffix, t.Name)); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", t.Name, err)
+			}
+			logger.Infof("removed %s (%s): %s\n", t.Name, formatBytes(t.SizeBytes), strings.Join(t.Reasons, ", "))
+		}
+		freed += t.SizeBytes
+	}
+
+	if dryRun {
+		logger.Infof("%d distribution(s) would be removed, freeing %s\n", len(targets), formatBytes(freed))
+	} else {
+		logger.Infof("%d distribution(s) removed, freeing %s\n", len(targets), formatBytes(freed))
+	}
+	return nil
+}
+
+func (x *InstalledDistribution) distribution() *manifest.IstioDistribution {
+	return &manifest.IstioDistribution{Version: x.Version, Flavor: x.Flavor, FlavorVersion: x.FlavorVersion}
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func TestSelectPruneTargets(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	yes, no := true, false
+	newDist := func(version, flavor string, flavorVersion int64, active bool, inManifest *bool, eol string, age time.Duration) *InstalledDistribution {
+		d := &InstalledDistribution{
+			Version: version, Flavor: flavor, FlavorVersion: flavorVersion, Active: active,
+			InManifest: inManifest, EndOfLife: eol, FetchedAt: now.Add(-age),
+		}
+		d.Name = d.distribution().String()
+		return d
+	}
+	ds := []*InstalledDistribution{
+		newDist("1.7.1", "tetrate", 0, false, &no, "2021-02-01", 100*24*time.Hour),
+		newDist("1.7.3", "tetrate", 0, false, &yes, "2021-02-01", 50*24*time.Hour),
+		newDist("1.7.3", "tetrate", 1, true, &yes, "2021-02-01", 40*24*time.Hour),
+		newDist("1.9.0", "tetrate", 0, false, &no, "2021-12-01", 20*24*time.Hour),
+		newDist("1.9.2", "tetrate", 0, false, &yes, "2021-12-01", time.Hour),
+		newDist("1.9.1", "istio", 0, false, &yes, "2021-12-01", time.Hour),
+	}
+
+	for _, c := range []struct {
+		name   string
+		policy PrunePolicy
+		exp    []string
+	}{
+		{
+			name: "all but active",
+			exp: []string{"1.7.1-tetrate-v0", "1.7.3-tetrate-v0", "1.9.0-tetrate-v0",
+				"1.9.2-tetrate-v0", "1.9.1-istio-v0"},
+		},
+		{
+			name:   "keep latest patches",
+			policy: PrunePolicy{KeepLatestPatches: 1},
+			// 1.7.3-tetrate-v1 is kept in 1.7-tetrate, and 1.9.2-tetrate-v0 in 1.9-tetrate
+			exp: []string{"1.7.1-tetrate-v0", "1.7.3-tetrate-v0", "1.9.0-tetrate-v0"},
+		},
+		{
+			name:   "keep latest patches more than installed",
+			policy: PrunePolicy{KeepLatestPatches: 3},
+		},
+		{
+			name:   "end of life",
+			policy: PrunePolicy{EndOfLife: true},
+			exp:    []string{"1.7.1-tetrate-v0", "1.7.3-tetrate-v0"},
+		},
+		{
+			name:   "not in manifest",
+			policy: PrunePolicy{NotInManifest: true},
+			exp:    []string{"1.7.1-tetrate-v0", "1.9.0-tetrate-v0"},
+		},
+		{
+			name:   "older than",
+			policy: PrunePolicy{OlderThan: 30 * 24 * time.Hour},
+			exp:    []string{"1.7.1-tetrate-v0", "1.7.3-tetrate-v0"},
+		},
+		{
+			name:   "combined",
+			policy: PrunePolicy{NotInManifest: true, OlderThan: 30 * 24 * time.Hour},
+			exp:    []string{"1.7.1-tetrate-v0", "1.7.3-tetrate-v0", "1.9.0-tetrate-v0"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			targets, err := SelectPruneTargets(ds, &c.policy, now)
+			require.NoError(t, err)
+
+			var actual []string
+			for _, target := range targets {
+				require.NotEmpty(t, target.Reasons)
+				actual = append(actual, target.Name)
+			}
+			require.Equal(t, c.exp, actual)
+		})
+	}
+
+	t.Run("reasons", func(t *testing.T) {
+		targets, err := SelectPruneTargets(ds[:1], &PrunePolicy{EndOfLife: true, NotInManifest: true}, now)
+		require.NoError(t, err)
+		require.Len(t, targets, 1)
+		require.Equal(t, []string{"end of life on 2021-02-01", "not in manifest"}, targets[0].Reasons)
+	})
+
+	t.Run("unknown manifest", func(t *testing.T) {
+		d := newDist("1.7.1", "tetrate", 0, false, nil, "", 0)
+		targets, err := SelectPruneTargets([]*InstalledDistribution{d}, &PrunePolicy{EndOfLife: true, NotInManifest: true}, now)
+		require.NoError(t, err)
+		require.Empty(t, targets)
+	})
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	var targets []*PruneTarget
+	for _, name := range []string{"1.7.1-tetrate-v0", "1.7.2-tetrate-v0"} {
+		p := filepath.Join(dir, istioDirSuffix, name, "bin", "istioctl")
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, make([]byte, 1024), 0755))
+		targets = append(targets, &PruneTarget{
+			InstalledDistribution: &InstalledDistribution{Name: name, SizeBytes: 1024},
+			Reasons:               []string{"not in manifest"},
+		})
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, Prune(dir, targets, true))
+		})
+		require.Equal(t, `would remove 1.7.1-tetrate-v0 (1.0 KiB): not in manifest
+would remove 1.7.2-tetrate-v0 (1.0 KiB): not in manifest
+2 distribution(s) would be removed, freeing 2.0 KiB
+`, buf.String())
+		for _, target := range targets {
+			require.DirExists(t, filepath.Join(dir, istioDirSuffix, target.Name))
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, Prune(dir, targets, false))
+		})
+		require.Contains(t, buf.String(), "2 distribution(s) removed, freeing 2.0 KiB\n")
+		for _, target := range targets {
+			require.NoDirExists(t, filepath.Join(dir, istioDirSuffix, target.Name))
+		}
+	})
+
+	t.Run("nothing", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, Prune(dir, nil, false))
+		})
+		require.Equal(t, "nothing to remove\n", buf.String())
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util"
+)
+
+// the metadata file written into each distribution directory on fetch, import and link
+const receiptFileName = "receipt.json"
+
+const (
+	ReceiptSourceFetch  = "fetch"
+	ReceiptSourceImport = "import"
+	ReceiptSourceLink   = "link"
+)
+
+// Receipt records where the installed distribution came from.
+type Receipt struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	Flavor        string `json:"flavor"`
+	FlavorVersion int64  `json:"flavor_version"`
+	// Source is how the distribution is installed, one of "fetch", "import" or "link"
+	Source string `json:"source"`
+	// URL is the download URL, the path of the imported archive or the path of the linked istioctl
+	URL string `json:"url"`
+	// SHA256 is the hex encoded sha256 checksum of the archive, empty for the linked istioctl
+	SHA256 string `json:"sha256,omitempty"`
+	// IstioctlSHA256 is the hex encoded sha256 checksum of the installed istioctl, empty for the linked istioctl
+	IstioctlSHA256 string    `json:"istioctl_sha256,omitempty"`
+	FetchedAt      time.Time `json:"fetched_at"`
+	GetmeshVersion string    `json:"getmesh_version"`
+
+	// the snapshot of the manifest at the time of installation, if the distribution is in the manifest
+	K8SVersions     []string `json:"k8s_versions,omitempty"`
+	IsSecurityPatch bool     `json:"is_security_patch,omitempty"`
+	ReleaseNotes    []string `json:"release_notes,omitempty"`
+	EndOfLife       string   `json:"end_of_life,omitempty"`
+}
+
+func newReceipt(d, found *manifest.IstioDistribution, source, url string) *Receipt {
+	ret := &Receipt{
+		Name:           d.String(),
+		Version:        d.Version,
+		Flavor:         d.Flavor,
+		FlavorVersion:  d.FlavorVersion,
+		Source:         source,
+		URL:            url,
+		FetchedAt:      time.Now().UTC().Truncate(time.Second),
+		GetmeshVersion: getmesh.GetVersion(),
+	}
+	if found != nil {
+		ret.K8SVersions = found.K8SVersions
+		ret.IsSecurityPatch = found.IsSecurityPatch
+		ret.ReleaseNotes = found.ReleaseNotes
+		ret.EndOfLife = found.EndOfLife
+	}
+	return ret
+}
+
+// Distribution returns the distribution recorded in the receipt.
+func (r *Receipt) Distribution() *manifest.IstioDistribution {
+	return &manifest.IstioDistribution{Version: r.Version, Flavor: r.Flavor, FlavorVersion: r.FlavorVersion}
+}
+
+// fill the checksums of the archive and the extracted istioctl
+func (r *Receipt) setChecksums(archive, istioctl string) error {
+	_, sum, err := hashFile(archive)
+	if err != nil {
+		return err
+	}
+	r.SHA256 = hex.EncodeToString(sum)
+
+	if _, sum, err = hashFile(istioctl); err != nil {
+		return err
+	}
+	r.IstioctlSHA256 = hex.EncodeToString(sum)
+	return nil
+}
+
+// GetReceipt returns the receipt of the installed distribution, or os.ErrNotExist
+// if it was installed by getmesh without receipts.
+func GetReceipt(homeDir string, d *manifest.IstioDistribution) (*Receipt, error) {
+	return readReceipt(filepath.Join(homeDir, istioDirSuffix, d.String()))
+}
+
+func readReceipt(dir string) (*Receipt, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, receiptFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret Receipt
+	if err := json.Unmarshal(raw, &ret); err != nil {
+		return nil, fmt.Errorf("invalid receipt in %s: %v", dir, err)
+	}
+	return &ret, nil
+}
+
+func writeReceipt(dir string, r *Receipt) error {
+	raw, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling receipt: %v", err)
+	}
+
+	if err := util.WriteFileAtomic(filepath.Join(dir, receiptFileName), raw, 0644); err != nil {
+		return fmt.Errorf("error writing receipt in %s: %v", dir, err)
+	}
+	return nil
+}
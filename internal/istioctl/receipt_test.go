@@ -0,0 +1,166 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+)
+
+func TestReceipt(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	getmesh.SetVersion("1.1.5")
+	defer getmesh.SetVersion("dev")
+
+	archive := newIstioArchive(t, "1.10.3", []byte("istioctl"))
+	archiveSum := sha256.Sum256(archive)
+	istioctlSum := sha256.Sum256([]byte("istioctl"))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer ts.Close()
+
+	d := &manifest.IstioDistribution{Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrate}
+	found := &manifest.IstioDistribution{
+		Version: "1.10.3", Flavor: manifest.IstioDistributionFlavorTetrate,
+		K8SVersions: []string{"1.20"}, IsSecurityPatch: true,
+		ReleaseNotes: []string{"https://istio.io/latest/news/releases/1.10.x/announcing-1.10.3/"},
+		EndOfLife:    "2022-01-01",
+	}
+
+	t.Run("fetch", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		require.NoError(t, fetchIstioctl(dir, d, found, ts.URL+"/ok.tar.gz", nil, true))
+
+		actual, err := GetReceipt(dir, d)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), actual.FetchedAt, time.Minute)
+		actual.FetchedAt = time.Time{}
+		require.Equal(t, &Receipt{
+			Name:            "1.10.3-tetrate-v0",
+			Version:         "1.10.3",
+			Flavor:          "tetrate",
+			Source:          ReceiptSourceFetch,
+			URL:             ts.URL + "/ok.tar.gz",
+			SHA256:          hex.EncodeToString(archiveSum[:]),
+			IstioctlSHA256:  hex.EncodeToString(istioctlSum[:]),
+			GetmeshVersion:  "1.1.5",
+			K8SVersions:     []string{"1.20"},
+			IsSecurityPatch: true,
+			ReleaseNotes:    []string{"https://istio.io/latest/news/releases/1.10.x/announcing-1.10.3/"},
+			EndOfLife:       "2022-01-01",
+		}, actual)
+
+		// show uses the receipt
+		ds, err := GetInstalledDistributions(dir, nil)
+		require.NoError(t, err)
+		require.Len(t, ds, 1)
+		require.Equal(t, ReceiptSourceFetch, ds[0].Source)
+		require.Equal(t, ts.URL+"/ok.tar.gz", ds[0].URL)
+		require.Equal(t, "2022-01-01", ds[0].EndOfLife)
+		require.False(t, ds[0].Unmanaged)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		p := filepath.Join(t.TempDir(), "istio.tar.gz")
+		require.NoError(t, os.WriteFile(p, archive, 0644))
+		require.NoError(t, Import(dir, p, d, &manifest.Manifest{IstioDistributions: []*manifest.IstioDistribution{found}}))
+
+		actual, err := GetReceipt(dir, d)
+		require.NoError(t, err)
+		require.Equal(t, ReceiptSourceImport, actual.Source)
+		require.Equal(t, p, actual.URL)
+		require.Equal(t, hex.EncodeToString(archiveSum[:]), actual.SHA256)
+		require.True(t, actual.IsSecurityPatch)
+	})
+
+	t.Run("link", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		bin := filepath.Join(t.TempDir(), "istioctl")
+		require.NoError(t, os.WriteFile(bin, []byte("istioctl"), 0755))
+		linked := &manifest.IstioDistribution{Version: "1.10.3", Flavor: "acme", FlavorVersion: 1}
+		require.NoError(t, Link(dir, bin, linked))
+
+		actual, err := GetReceipt(dir, linked)
+		require.NoError(t, err)
+		require.Equal(t, ReceiptSourceLink, actual.Source)
+		require.Equal(t, bin, actual.URL)
+		require.Empty(t, actual.IstioctlSHA256)
+
+		ds, err := GetInstalledDistributions(dir, nil)
+		require.NoError(t, err)
+		require.Len(t, ds, 1)
+		require.True(t, ds[0].Unmanaged)
+	})
+
+	t.Run("name from receipt", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		// the directory name does not match the receipt
+		other := filepath.Join(dir, istioDirSuffix, "1.10.4-tetrate-v0")
+		require.NoError(t, os.MkdirAll(other, 0755))
+		require.NoError(t, writeReceipt(other, newReceipt(d, nil, ReceiptSourceFetch, "")))
+
+		ds, err := GetInstalledDistributions(dir, nil)
+		require.NoError(t, err)
+		require.Empty(t, ds)
+	})
+
+	t.Run("verify against receipt", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, getmesh.SetIstioVersion(dir, nil))
+		require.NoError(t, fetchIstioctl(dir, d, found, ts.URL+"/ok.tar.gz", nil, true))
+
+		// no checksum in the manifest
+		ms := &manifest.Manifest{IstioDistributions: []*manifest.IstioDistribution{found}}
+		rs, err := Verify(dir, ms)
+		require.NoError(t, err)
+		// the receipt can be rewritten along with the binary, so it is not verified
+		require.Equal(t, VerifyStatusReceiptOnly, rs[0].Status)
+		require.Contains(t, rs[0].Detail, "no istioctl checksum is published")
+		require.True(t, rs[0].Unverified())
+		require.False(t, rs[0].Broken())
+
+		// not in the manifest anymore
+		rs, err = Verify(dir, &manifest.Manifest{})
+		require.NoError(t, err)
+		require.Equal(t, VerifyStatusReceiptOnly, rs[0].Status)
+		require.Contains(t, rs[0].Detail, "not in the manifest")
+		require.False(t, rs[0].Broken())
+
+		require.NoError(t, os.WriteFile(GetIstioctlPath(dir, d), []byte("tampered"), 0755))
+		rs, err = Verify(dir, ms)
+		require.NoError(t, err)
+		require.Equal(t, VerifyStatusMismatch, rs[0].Status)
+		require.True(t, rs[0].Broken())
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+)
+
+// ShimName is the name of the shim on PATH. The shim is a symlink to the getmesh binary,
+// which runs the active istioctl when invoked by this name.
+const ShimName = "istioctl"
+
+// GetBinDir returns the directory of the getmesh binary and the shim, to be put on PATH.
+func GetBinDir(homeDir string) string {
+	return filepath.Join(homeDir, "bin")
+}
+
+// InstallShim links the shim in the bin directory to the running getmesh binary, unless it is already linked.
+// A file at the shim path which is not a symlink is left untouched.
+func InstallShim(homeDir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error finding the getmesh binary: %v", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("error finding the getmesh binary: %v", err)
+	}
+	return installShim(GetBinDir(homeDir), exe)
+}
+
+func installShim(binDir, exe string) error {
+	// prefer the relative link so that it survives moving the getmesh home
+	target := exe
+	if filepath.Dir(exe) == binDir {
+		target = filepath.Base(exe)
+	}
+
+	shim := filepath.Join(binDir, ShimName)
+	if info, err := os.Lstat(shim); err == nil {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%s already exists and is not managed by getmesh", shim)
+		}
+		if current, err := os.Readlink(shim); err == nil && current == target {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+
+	// replace the link at once so that the shim is always available
+	tmp := shim + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("error creating %s: %v", shim, err)
+	}
+	if err := os.Rename(tmp, shim); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error creating %s: %v", shim, err)
+	}
+	return nil
+}
+
+// ExecShim replaces the current process with the active istioctl, so that the exit code and signals
+// are handled by istioctl itself as if it were invoked directly.
+func ExecShim(homeDir string, args []string) error {
+	// the lock file is closed on exec, which releases the shared lock once istioctl is started
+	unlock, err := getmesh.RLockHome(homeDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cur, err := GetCurrentExecutable(homeDir)
+	if err != nil {
+		return err
+	}
+
+	path := GetIstioctlPath(homeDir, cur)
+	return syscall.Exec(path, append([]string{path}, args...), os.Environ())
+}
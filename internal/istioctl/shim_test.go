@@ -0,0 +1,63 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_installShim(t *testing.T) {
+	t.Run("relative", func(t *testing.T) {
+		bin := GetBinDir(t.TempDir())
+		exe := filepath.Join(bin, "getmesh")
+		require.NoError(t, installShim(bin, exe))
+
+		actual, err := os.Readlink(filepath.Join(bin, ShimName))
+		require.NoError(t, err)
+		require.Equal(t, "getmesh", actual)
+
+		// idempotent
+		require.NoError(t, installShim(bin, exe))
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		bin := GetBinDir(t.TempDir())
+		require.NoError(t, installShim(bin, "/usr/local/bin/getmesh"))
+		require.NoError(t, installShim(bin, "/opt/getmesh/getmesh"))
+
+		actual, err := os.Readlink(filepath.Join(bin, ShimName))
+		require.NoError(t, err)
+		require.Equal(t, "/opt/getmesh/getmesh", actual)
+
+		_, err = os.Lstat(filepath.Join(bin, ShimName+".tmp"))
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("not managed", func(t *testing.T) {
+		bin := GetBinDir(t.TempDir())
+		require.NoError(t, os.MkdirAll(bin, 0755))
+		shim := filepath.Join(bin, ShimName)
+		require.NoError(t, os.WriteFile(shim, []byte("istioctl"), 0755))
+
+		require.Error(t, installShim(bin, "/usr/local/bin/getmesh"))
+		actual, err := os.ReadFile(shim)
+		require.NoError(t, err)
+		require.Equal(t, "istioctl", string(actual))
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package istioctl
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// isTerminalForeground returns true if getmesh is in the foreground process group of its controlling terminal,
+// to which the terminal delivers Ctrl-C.
+func isTerminalForeground() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		// no controlling terminal, e.g. in CI or under a supervisor
+		return false
+	}
+	defer tty.Close()
+
+	pgrp, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	return err == nil && pgrp == unix.Getpgrp()
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+const (
+	VerifyStatusOK            = "ok"
+	VerifyStatusMismatch      = "mismatch"
+	VerifyStatusMissing       = "missing"
+	VerifyStatusNotInManifest = "not in manifest"
+	VerifyStatusNoChecksum    = "no checksum"
+	// VerifyStatusReceiptOnly is the istioctl only matching the checksum in its receipt. It is not verified since
+	// the receipt sits next to the binary and can be rewritten along with it.
+	VerifyStatusReceiptOnly = "receipt only"
+	VerifyStatusUnmanaged   = "unmanaged"
+)
+
+// VerifyResult is the result of verifying the istioctl of an installed distribution.
+type VerifyResult struct {
+	Distribution *manifest.IstioDistribution
+	Status       string
+	Detail       string
+}
+
+// Broken returns true if the istioctl is tampered or corrupted, and should be fetched again.
+func (r *VerifyResult) Broken() bool {
+	return r.Status == VerifyStatusMismatch || r.Status == VerifyStatusMissing
+}
+
+// Unverified returns true if the istioctl is not tampered as far as known, but cannot be verified against the manifest.
+// The linked istioctl is not managed by getmesh, so it is never taken as unverified.
+func (r *VerifyResult) Unverified() bool {
+	switch r.Status {
+	case VerifyStatusNotInManifest, VerifyStatusNoChecksum, VerifyStatusReceiptOnly:
+		return true
+	}
+	return false
+}
+
+// Verify re-hashes the istioctl of each installed distribution and compares it with the checksum in the manifest.
+func Verify(homeDir string, ms *manifest.Manifest) ([]*VerifyResult, error) {
+	return verify(homeDir, ms, runtime.GOOS, runtime.GOARCH)
+}
+
+func verify(homeDir string, ms *manifest.Manifest, goos, goarch string) ([]*VerifyResult, error) {
+	ds, err := GetInstalledDistributions(homeDir, ms)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*VerifyResult, 0, len(ds))
+	for _, d := range ds {
+		r := &VerifyResult{Distribution: d.distribution()}
+		ret = append(ret, r)
+
+		if d.Unmanaged {
+			binary, _ := GetLinkedBinary(homeDir, r.Distribution)
+			r.Status, r.Detail = VerifyStatusUnmanaged, "linked to "+binary
+			continue
+		}
+
+		size, sum, err := hashFile(d.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			r.Status, r.Detail = VerifyStatusMissing, d.Path+" does not exist"
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var found *manifest.IstioDistribution
+		for _, m := range ms.IstioDistributions {
+			if m.Equal(r.Distribution) {
+				found = m
+				break
+			}
+		}
+
+		// prefer the checksum published in the manifest, and fall back to the one recorded on installation
+		var expected, against string
+		if found != nil {
+			if a := found.GetArtifact(goos, goarch); a != nil && len(a.IstioctlSHA256) != 0 {
+				expected, against = a.IstioctlSHA256, "manifest"
+			}
+		}
+		if len(expected) == 0 && d.receipt != nil && len(d.receipt.IstioctlSHA256) != 0 {
+			expected, against = d.receipt.IstioctlSHA256, "receipt"
+		}
+
+		actual := hex.EncodeToString(sum)
+		switch {
+		case len(expected) == 0 && found == nil:
+			r.Status, r.Detail = VerifyStatusNotInManifest, "cannot be verified"
+		case len(expected) == 0:
+			r.Status, r.Detail = VerifyStatusNoChecksum, fmt.Sprintf("no istioctl checksum is published for %s/%s", goos, goarch)
+		case !strings.EqualFold(actual, expected):
+			r.Status, r.Detail = VerifyStatusMismatch, fmt.Sprintf("expected sha256 %s in the %s but got %s (%d bytes)",
+				expected, against, actual, size)
+		case against == "receipt" && found == nil:
+			r.Status, r.Detail = VerifyStatusReceiptOnly, "matches the receipt, but is not in the manifest"
+		case against == "receipt":
+			r.Status, r.Detail = VerifyStatusReceiptOnly,
+				fmt.Sprintf("matches the receipt, but no istioctl checksum is published for %s/%s", goos, goarch)
+		default:
+			r.Status, r.Detail = VerifyStatusOK, "verified against the manifest"
+		}
+	}
+	return ret, nil
+}
+
+// PrintVerifyResults prints the results in the table.
+func PrintVerifyResults(rs []*VerifyResult) {
+	if len(rs) == 0 {
+		logger.Infof("No Istioctl installed yet\n")
+		return
+	}
+
+	data := make([][]string, len(rs))
+	for i, r := range rs {
+		data[i] = []string{r.Distribution.String(), r.Status, r.Detail}
+	}
+
+	table := tablewriter.NewWriter(logger.GetWriter())
+	table.SetHeader([]string{"NAME", "STATUS", "DETAIL"})
+	util.FlushTable(table, data)
+}
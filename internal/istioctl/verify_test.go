@@ -0,0 +1,131 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioctl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/manifest"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func Test_verify(t *testing.T) {
+	sum := sha256.Sum256([]byte("istioctl"))
+	ms := &manifest.Manifest{
+		IstioDistributions: []*manifest.IstioDistribution{
+			{Version: "1.7.1", Flavor: "tetrate", Artifacts: []*manifest.Artifact{
+				{OS: "linux", Arch: "amd64", IstioctlSHA256: hex.EncodeToString(sum[:])},
+			}},
+			{Version: "1.7.2", Flavor: "tetrate", Artifacts: []*manifest.Artifact{
+				{OS: "linux", Arch: "amd64", IstioctlSHA256: hex.EncodeToString(sum[:])},
+			}},
+			{Version: "1.7.3", Flavor: "tetrate", Artifacts: []*manifest.Artifact{
+				{OS: "linux", Arch: "amd64", IstioctlSHA256: hex.EncodeToString(sum[:])},
+			}},
+			{Version: "1.7.4", Flavor: "tetrate", Artifacts: []*manifest.Artifact{
+				{OS: "darwin", Arch: "amd64", IstioctlSHA256: hex.EncodeToString(sum[:])},
+			}},
+		},
+	}
+
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"1.7.1-tetrate-v0": "istioctl",
+		"1.7.2-tetrate-v0": "tampered",
+		"1.7.4-tetrate-v0": "istioctl",
+		"1.7.5-tetrate-v0": "istioctl",
+	} {
+		p := filepath.Join(dir, istioDirSuffix, name, "bin", "istioctl")
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(body), 0755))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, istioDirSuffix, "1.7.3-tetrate-v0"), 0755))
+
+	rs, err := verify(dir, ms, "linux", "amd64")
+	require.NoError(t, err)
+
+	actual := map[string]string{}
+	for _, r := range rs {
+		actual[r.Distribution.String()] = r.Status
+	}
+	require.Equal(t, map[string]string{
+		"1.7.1-tetrate-v0": VerifyStatusOK,
+		"1.7.2-tetrate-v0": VerifyStatusMismatch,
+		"1.7.3-tetrate-v0": VerifyStatusMissing,
+		"1.7.4-tetrate-v0": VerifyStatusNoChecksum,
+		"1.7.5-tetrate-v0": VerifyStatusNotInManifest,
+	}, actual)
+
+	for _, r := range rs {
+		exp := r.Status == VerifyStatusMismatch || r.Status == VerifyStatusMissing
+		require.Equal(t, exp, r.Broken(), r.Distribution.String())
+		exp = r.Status == VerifyStatusNoChecksum || r.Status == VerifyStatusNotInManifest
+		require.Equal(t, exp, r.Unverified(), r.Distribution.String())
+	}
+
+	buf := logger.ExecuteWithLock(func() { PrintVerifyResults(rs) })
+	require.Contains(t, buf.String(), "1.7.2-tetrate-v0")
+	require.Contains(t, buf.String(), "expected sha256 "+hex.EncodeToString(sum[:]))
+}
+
+func TestRefetch(t *testing.T) {
+	getmesh.GlobalConfigMux.Lock()
+	defer getmesh.GlobalConfigMux.Unlock()
+
+	archive := newIstioArchive(t, "1.7.1", []byte("istioctl"))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer ts.Close()
+
+	sum := sha256.Sum256([]byte("istioctl"))
+	ms := &manifest.Manifest{
+		IstioDistributions: []*manifest.IstioDistribution{
+			{Version: "1.7.1", Flavor: "tetrate", Artifacts: []*manifest.Artifact{
+				{OS: runtime.GOOS, Arch: runtime.GOARCH, IstioctlSHA256: hex.EncodeToString(sum[:])},
+			}},
+		},
+		ArtifactURLTemplate: ts.URL + "/{{.Distribution}}.tar.gz",
+	}
+
+	dir := t.TempDir()
+	d := &manifest.IstioDistribution{Version: "1.7.1", Flavor: "tetrate"}
+	require.NoError(t, getmesh.SetIstioVersion(dir, d))
+	p := GetIstioctlPath(dir, d)
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+	require.NoError(t, os.WriteFile(p, []byte("tampered"), 0755))
+
+	rs, err := Verify(dir, ms)
+	require.NoError(t, err)
+	require.Len(t, rs, 1)
+	require.Equal(t, VerifyStatusMismatch, rs[0].Status)
+
+	require.NoError(t, Refetch(dir, d, ms))
+	rs, err = Verify(dir, ms)
+	require.NoError(t, err)
+	require.Equal(t, VerifyStatusOK, rs[0].Status)
+
+	require.Error(t, Refetch(dir, &manifest.IstioDistribution{Version: "1.7.2", Flavor: "tetrate"}, ms))
+}
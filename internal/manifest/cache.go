@@ -0,0 +1,258 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/getmesh/internal/util"
+	"github.com/tetratelabs/getmesh/internal/util/filelock"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+const (
+	// DefaultCacheTTL is the duration in which the cached manifest is used without revalidation.
+	DefaultCacheTTL = time.Hour
+
+	cacheDirName        = "cache"
+	cachedManifestName  = "manifest.json"
+	cachedSignatureName = cachedManifestName + signatureSuffix
+	cachedMetadataName  = "manifest.meta.json"
+	// held while the cached files are read or written, so that concurrent getmesh processes never mix them
+	cacheLockName = ".lock"
+)
+
+type cacheMetadata struct {
+	// the source URL from which the cached manifest was fetched
+	Source       string    `json:"source"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+var (
+	// the cache is disabled when cacheDir is empty
+	cacheDir string
+	cacheTTL = DefaultCacheTTL
+	offline  bool
+
+	// the raw manifest loaded in this process
+	memo    []byte
+	memoMux sync.Mutex
+
+	// ErrNoCachedManifest is returned in offline mode when no manifest has been cached. Unlike the cached manifest
+	// failing the verification, this means there is no manifest at all.
+	ErrNoCachedManifest = errors.New("no cached manifest available in offline mode")
+)
+
+// SetCache enables the on-disk manifest cache under the getmesh home directory.
+// The cached manifest is used without revalidation for the given ttl.
+func SetCache(homedir string, ttl time.Duration) {
+	if len(homedir) == 0 {
+		cacheDir = ""
+	} else {
+		cacheDir = filepath.Join(homedir, cacheDirName)
+	}
+	cacheTTL = ttl
+}
+
+// SetOffline makes FetchManifest serve the cached manifest without accessing any source.
+func SetOffline(b bool) {
+	offline = b
+}
+
+// load the raw manifest once per process, from the cache or the given sources
+func loadManifest(ss []Source) ([]byte, error) {
+	memoMux.Lock()
+	defer memoMux.Unlock()
+	if memo != nil {
+		return memo, nil
+	}
+
+	raw, err := loadManifestWithCache(ss, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	memo = raw
+	return raw, nil
+}
+
+// LoadCachedManifest returns the manifest cached by the last FetchManifest without accessing any source,
+// so that the local commands such as "getmesh show" work offline and instantly.
+func LoadCachedManifest() (*Manifest, error) {
+	if p := os.Getenv("GETMESH_TEST_MANIFEST_PATH"); len(p) != 0 {
+		return readTestManifest(p)
+	} else if len(cacheDir) == 0 {
+		return nil, errors.New("the manifest cache is disabled")
+	}
+
+	raw, sig, _, err := readCacheLocked(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	// the unverified manifest was already warned when it was cached
+	if err := checkSignature(raw, sig); err != nil && !acceptUnverified(err) {
+		return nil, fmt.Errorf("error verifying cached manifest: %w", err)
+	}
+	return parseManifest(raw)
+}
+
+func loadManifestWithCache(ss []Source, now time.Time) ([]byte, error) {
+	if len(cacheDir) == 0 {
+		if offline {
+			return nil, fmt.Errorf("%w: the manifest cache is disabled", ErrNoCachedManifest)
+		}
+		res, err := fetchManifestFromSources(ss, nil)
+		if err != nil {
+			return nil, err
+		}
+		return res.raw, nil
+	}
+
+	cached, cachedSig, meta, cacheErr := readCacheLocked(cacheDir)
+	if cacheErr == nil {
+		// the keys may have changed since the manifest was cached
+		if err := verifySignature(cached, cachedSig); err != nil {
+			cacheErr = fmt.Errorf("error verifying cached manifest: %w", err)
+		}
+	}
+
+	if offline {
+		if errors.Is(cacheErr, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %v", ErrNoCachedManifest, cacheErr)
+		} else if cacheErr != nil {
+			return nil, fmt.Errorf("the cached manifest is not usable in offline mode: %w", cacheErr)
+		}
+		logger.Infof("offline mode: using the cached manifest fetched %s ago\n", cacheAge(meta, now))
+		return cached, nil
+	}
+
+	// the cache is stale once its source is removed from the configured ones, e.g. by --manifest-url
+	if cacheErr == nil && now.Sub(meta.FetchedAt) < cacheTTL && hasSource(ss, meta.Source) {
+		return cached, nil
+	}
+
+	var conditional *cacheMetadata
+	if cacheErr == nil {
+		conditional = meta
+	}
+
+	res, err := fetchManifestFromSources(ss, conditional)
+	if err != nil {
+		if cacheErr != nil {
+			return nil, err
+		}
+		logger.Warnf("%v\nFalling back to the cached manifest fetched %s ago\n", err, cacheAge(meta, now))
+		return cached, nil
+	}
+
+	raw, sig := res.raw, res.signature
+	next := &cacheMetadata{Source: res.source, ETag: res.etag, LastModified: res.lastModified, FetchedAt: now}
+	if res.notModified {
+		raw, sig = cached, cachedSig
+		if len(next.ETag) == 0 {
+			next.ETag = meta.ETag
+		}
+		if len(next.LastModified) == 0 {
+			next.LastModified = meta.LastModified
+		}
+	}
+
+	if err := writeCacheLocked(cacheDir, raw, sig, next); err != nil {
+		logger.Warnf("failed to cache the manifest: %v\n", err)
+	}
+	return raw, nil
+}
+
+func hasSource(ss []Source, url string) bool {
+	for _, s := range ss {
+		if s.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+func readCacheLocked(dir string) (raw, signature []byte, meta *cacheMetadata, err error) {
+	unlock, err := filelock.Lock(filepath.Join(dir, cacheLockName))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer unlock()
+	return readCache(dir)
+}
+
+func writeCacheLocked(dir string, raw, signature []byte, meta *cacheMetadata) error {
+	unlock, err := filelock.Lock(filepath.Join(dir, cacheLockName))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeCache(dir, raw, signature, meta)
+}
+
+func readCache(dir string) (raw, signature []byte, meta *cacheMetadata, err error) {
+	rawMeta, err := ioutil.ReadFile(filepath.Join(dir, cachedMetadataName))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading cached manifest metadata: %w", err)
+	}
+
+	if err := json.Unmarshal(rawMeta, &meta); err != nil {
+		return nil, nil, nil, fmt.Errorf("error unmarshalling cached manifest metadata: %v", err)
+	}
+
+	raw, err = ioutil.ReadFile(filepath.Join(dir, cachedManifestName))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading cached manifest: %w", err)
+	}
+
+	// the signature is absent when the manifest was cached unsigned or with verification skipped
+	signature, err = ioutil.ReadFile(filepath.Join(dir, cachedSignatureName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil, fmt.Errorf("error reading cached manifest signature: %w", err)
+	}
+	return raw, signature, meta, nil
+}
+
+func writeCache(dir string, raw, signature []byte, meta *cacheMetadata) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	rawMeta, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("error marshaling cached manifest metadata: %v", err)
+	}
+
+	// write the metadata last so that it never refers to a stale manifest
+	if err := util.WriteFileAtomic(filepath.Join(dir, cachedManifestName), raw, 0644); err != nil {
+		return err
+	}
+	if err := util.WriteFileAtomic(filepath.Join(dir, cachedSignatureName), signature, 0644); err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(filepath.Join(dir, cachedMetadataName), rawMeta, 0644)
+}
+
+func cacheAge(meta *cacheMetadata, now time.Time) time.Duration {
+	return now.Sub(meta.FetchedAt).Round(time.Second)
+}
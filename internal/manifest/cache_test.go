@@ -0,0 +1,224 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func Test_loadManifestWithCache(t *testing.T) {
+	GlobalManifestURLMux.Lock()
+	defer GlobalManifestURLMux.Unlock()
+	defer func() {
+		SetCache("", DefaultCacheTTL)
+		SetOffline(false)
+	}()
+
+	raw, err := json.Marshal(&Manifest{
+		IstioDistributions: []*IstioDistribution{
+			{Version: "1.7.6", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+		},
+	})
+	require.NoError(t, err)
+
+	const etag = `"v1"`
+	var requests, notModified int32
+	signed := signedHandler(trustTestKey(t), raw)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, signatureSuffix) {
+			signed(w, r)
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		signed(w, r)
+	}))
+	defer ts.Close()
+
+	home := t.TempDir()
+	ss := []Source{{URL: ts.URL + "/manifest.json"}}
+	now := time.Now()
+	SetCache(home, time.Hour)
+
+	t.Run("miss", func(t *testing.T) {
+		actual, err := loadManifestWithCache(ss, now)
+		require.NoError(t, err)
+		require.Equal(t, raw, actual)
+		require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+		_, _, meta, err := readCache(cacheDir)
+		require.NoError(t, err)
+		require.Equal(t, ss[0].URL, meta.Source)
+		require.Equal(t, etag, meta.ETag)
+	})
+
+	t.Run("fresh", func(t *testing.T) {
+		actual, err := loadManifestWithCache(ss, now.Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, raw, actual)
+		require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("revalidate", func(t *testing.T) {
+		actual, err := loadManifestWithCache(ss, now.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, raw, actual)
+		require.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+
+		_, _, meta, err := readCache(cacheDir)
+		require.NoError(t, err)
+		require.Equal(t, etag, meta.ETag)
+		require.True(t, meta.FetchedAt.Equal(now.Add(2*time.Hour)))
+	})
+
+	t.Run("source changed", func(t *testing.T) {
+		other := []Source{{URL: ts.URL + "/other/manifest.json"}}
+		before := atomic.LoadInt32(&requests)
+		// fresh but fetched from the source no longer configured
+		actual, err := loadManifestWithCache(other, now.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, raw, actual)
+		require.Equal(t, before+1, atomic.LoadInt32(&requests))
+
+		_, _, meta, err := readCache(cacheDir)
+		require.NoError(t, err)
+		require.Equal(t, other[0].URL, meta.Source)
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			actual, err := loadManifestWithCache([]Source{{URL: "file:///non-existent/manifest.json"}}, now.Add(5*time.Hour))
+			require.NoError(t, err)
+			require.Equal(t, raw, actual)
+		})
+		require.Contains(t, buf.String(), "Falling back to the cached manifest fetched 3h0m0s ago")
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		SetOffline(true)
+		defer SetOffline(false)
+
+		before := atomic.LoadInt32(&requests)
+		buf := logger.ExecuteWithLock(func() {
+			actual, err := loadManifestWithCache(ss, now.Add(4*time.Hour))
+			require.NoError(t, err)
+			require.Equal(t, raw, actual)
+		})
+		require.Equal(t, "offline mode: using the cached manifest fetched 2h0m0s ago\n", buf.String())
+		require.Equal(t, before, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("offline json", func(t *testing.T) {
+		SetOffline(true)
+		defer SetOffline(false)
+
+		diag := new(bytes.Buffer)
+		buf := logger.ExecuteWithLock(func() {
+			logger.SetDiagnosticWriter(diag)
+			defer logger.SetDiagnosticWriter(nil)
+
+			actual, err := loadManifestWithCache(ss, now.Add(4*time.Hour))
+			require.NoError(t, err)
+			ms, err := parseManifest(actual)
+			require.NoError(t, err)
+			ds, err := ListDistributions(ms, nil, nil, &ListFilter{})
+			require.NoError(t, err)
+			require.NoError(t, PrintDistributions(ds, "json"))
+		})
+
+		// the offline message does not break the json output
+		var ds []*ListedDistribution
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &ds), buf.String())
+		require.Len(t, ds, 1)
+		require.Contains(t, diag.String(), "offline mode: using the cached manifest")
+	})
+
+	t.Run("load cached", func(t *testing.T) {
+		before := atomic.LoadInt32(&requests)
+		actual, err := LoadCachedManifest()
+		require.NoError(t, err)
+		require.Len(t, actual.IstioDistributions, 1)
+		require.Equal(t, before, atomic.LoadInt32(&requests))
+
+		SetCache(t.TempDir(), time.Hour)
+		defer SetCache(home, time.Hour)
+		_, err = LoadCachedManifest()
+		require.Error(t, err)
+	})
+
+	t.Run("untrusted cache", func(t *testing.T) {
+		SetOffline(true)
+		defer SetOffline(false)
+		// the key is rotated after the manifest was cached
+		trustTestKey(t)
+
+		_, err := loadManifestWithCache(ss, now.Add(4*time.Hour))
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+		// the manifest is there but not trusted
+		require.NotErrorIs(t, err, ErrNoCachedManifest)
+	})
+
+	t.Run("offline without cache", func(t *testing.T) {
+		SetOffline(true)
+		defer SetOffline(false)
+		SetCache(t.TempDir(), time.Hour)
+		defer SetCache(home, time.Hour)
+
+		_, err := loadManifestWithCache(ss, now)
+		require.ErrorIs(t, err, ErrNoCachedManifest)
+
+		SetCache("", time.Hour)
+		_, err = loadManifestWithCache(ss, now)
+		require.ErrorIs(t, err, ErrNoCachedManifest)
+	})
+}
+
+func Test_loadManifest(t *testing.T) {
+	GlobalManifestURLMux.Lock()
+	defer GlobalManifestURLMux.Unlock()
+	defer func() { memo = nil }()
+
+	var requests int32
+	signed := signedHandler(trustTestKey(t), []byte(`{"istio_distributions":[]}`))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		signed(w, r)
+	}))
+	defer ts.Close()
+
+	memo = nil
+	for i := 0; i < 3; i++ {
+		_, err := loadManifest([]Source{{URL: ts.URL + "/manifest.json"}})
+		require.NoError(t, err)
+	}
+	// the manifest and its signature
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
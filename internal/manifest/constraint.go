@@ -0,0 +1,152 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Constraint is the version range in the npm and Helm style, e.g. "1.18", "~1.18", "^1.18", "1.18.x" or ">=1.17.5 <1.19".
+//
+// Comparators separated by spaces or commas are combined with AND, and the sets of them separated by "||" with OR.
+// See github.com/Masterminds/semver for the operators. As in npm, a pre-release only matches a set having a comparator
+// with the pre-release of the same x.y.z, and the other comparators in the set are checked against that x.y.z.
+type Constraint struct {
+	raw  string
+	sets []*comparatorSet
+}
+
+type comparatorSet struct {
+	all         *semver.Constraints
+	comparators []*comparator
+}
+
+type comparator struct {
+	c *semver.Constraints
+	// the version with the pre-release in the comparator, nil otherwise
+	pre *semver.Version
+}
+
+// a single comparator in the set, e.g. ">= 1.17.5", "~1.18" or "1.20.0-beta.1"
+var comparatorRegexp = regexp.MustCompile(`([=!<>~^]+\s*)?v?[0-9xX*]+(\.[0-9xX*]+)*(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?`)
+
+// ParseConstraint parses the constraint expression.
+func ParseConstraint(in string) (*Constraint, error) {
+	ret := &Constraint{raw: in}
+	for _, set := range strings.Split(in, "||") {
+		s, err := parseComparatorSet(set)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %v", in, err)
+		}
+		ret.sets = append(ret.sets, s)
+	}
+	return ret, nil
+}
+
+func parseComparatorSet(in string) (*comparatorSet, error) {
+	all, err := semver.NewConstraint(in)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &comparatorSet{all: all}
+	for _, f := range comparatorRegexp.FindAllString(in, -1) {
+		c, err := semver.NewConstraint(f)
+		if err != nil {
+			return nil, err
+		}
+
+		cp := &comparator{c: c}
+		if v, err := semver.NewVersion(strings.TrimLeft(f, "=!<>~^ ")); err == nil && len(v.Prerelease()) != 0 {
+			cp.pre = v
+		}
+		ret.comparators = append(ret.comparators, cp)
+	}
+	return ret, nil
+}
+
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+// Check returns true if the version satisfies the constraint.
+func (c *Constraint) Check(v *Version) bool {
+	for _, set := range c.sets {
+		if set.check(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *comparatorSet) check(v *Version) bool {
+	if !v.IsPreRelease() {
+		return s.all.Check(v.v)
+	}
+
+	// semver.Constraints never matches the pre-release with the comparators without the pre-release,
+	// so check them against x.y.z instead
+	release, err := v.v.SetPrerelease("")
+	if err != nil {
+		return false
+	}
+
+	var ok bool
+	for _, c := range s.comparators {
+		if c.pre == nil {
+			if !c.c.Check(&release) {
+				return false
+			}
+			continue
+		}
+
+		if !c.c.Check(v.v) {
+			return false
+		}
+		ok = ok || (c.pre.Major() == v.v.Major() && c.pre.Minor() == v.v.Minor() && c.pre.Patch() == v.v.Patch())
+	}
+	return ok
+}
+
+// GetLatestDistributionMatching returns the greatest distribution of the flavor whose version satisfies the constraint,
+// or nil if none of them does.
+func GetLatestDistributionMatching(c *Constraint, flavor string, ms *Manifest) (*IstioDistribution, error) {
+	var ret *IstioDistribution
+	for _, d := range ms.IstioDistributions {
+		if d.Flavor != flavor {
+			continue
+		}
+
+		v, err := d.ParseVersion()
+		if err != nil {
+			return nil, err
+		} else if !c.Check(v) {
+			continue
+		}
+
+		if ret == nil {
+			ret = d
+		} else if ok, err := d.GreaterThan(ret); err != nil {
+			return nil, err
+		} else if ok {
+			ret = d
+		}
+	}
+	return ret, nil
+}
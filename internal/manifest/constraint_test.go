@@ -0,0 +1,104 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraint_Check(t *testing.T) {
+	for _, c := range []struct {
+		constraint string
+		ok, ng     []string
+	}{
+		{constraint: "1.18.2", ok: []string{"1.18.2"}, ng: []string{"1.18.3", "1.18.2-rc.0"}},
+		{constraint: "=1.18.2", ok: []string{"1.18.2"}, ng: []string{"1.18.1"}},
+		{constraint: "1.18", ok: []string{"1.18.0", "1.18.9"}, ng: []string{"1.17.9", "1.19.0", "1.18.1-rc.0"}},
+		{constraint: "1.18.x", ok: []string{"1.18.0", "1.18.9"}, ng: []string{"1.19.0"}},
+		{constraint: "1.*", ok: []string{"1.0.0", "1.99.1"}, ng: []string{"2.0.0", "0.9.0"}},
+		{constraint: "*", ok: []string{"0.0.1", "1.18.2"}, ng: []string{"1.18.2-beta.1"}},
+		{constraint: "~1.18", ok: []string{"1.18.0", "1.18.9"}, ng: []string{"1.19.0", "1.17.9"}},
+		{constraint: "~1.18.2", ok: []string{"1.18.2", "1.18.9"}, ng: []string{"1.18.1", "1.19.0"}},
+		{constraint: "~1", ok: []string{"1.0.0", "1.99.0"}, ng: []string{"2.0.0"}},
+		{constraint: "^1.18", ok: []string{"1.18.0", "1.99.0"}, ng: []string{"1.17.9", "2.0.0"}},
+		{constraint: "^1.18.2", ok: []string{"1.18.2", "1.20.0"}, ng: []string{"1.18.1", "2.0.0"}},
+		{constraint: "^0.8", ok: []string{"0.8.0", "0.8.9"}, ng: []string{"0.9.0"}},
+		{constraint: "^0.0.3", ok: []string{"0.0.3"}, ng: []string{"0.0.4"}},
+		{constraint: ">=1.17.5 <1.19", ok: []string{"1.17.5", "1.18.9"}, ng: []string{"1.17.4", "1.19.0", "1.19.0-rc.0"}},
+		{constraint: ">= 1.17.5, < 1.19", ok: []string{"1.17.5", "1.18.9"}, ng: []string{"1.17.4", "1.19.0"}},
+		{constraint: ">1.18", ok: []string{"1.19.0"}, ng: []string{"1.18.9"}},
+		{constraint: ">1.18.2", ok: []string{"1.18.3"}, ng: []string{"1.18.2"}},
+		{constraint: "<=1.18", ok: []string{"1.18.9", "1.0.0"}, ng: []string{"1.19.0"}},
+		{constraint: "<=1.18.2", ok: []string{"1.18.2"}, ng: []string{"1.18.3"}},
+		{constraint: "1.18 !=1.18.3", ok: []string{"1.18.2", "1.18.4"}, ng: []string{"1.18.3"}},
+		{constraint: "~1.17 || ~1.19", ok: []string{"1.17.2", "1.19.0"}, ng: []string{"1.18.0", "1.20.0"}},
+		{constraint: ">=1.20.0-beta.1 <1.21", ok: []string{"1.20.0-beta.1", "1.20.0-rc.0", "1.20.0", "1.20.1"},
+			ng: []string{"1.20.0-alpha.1", "1.20.1-rc.0"}},
+		{constraint: ">=1.20.0-beta.1, <1.21", ok: []string{"1.20.0-beta.1", "1.20.0-rc.0", "1.20.0"},
+			ng: []string{"1.20.0-alpha.1", "1.21.0-rc.0"}},
+		{constraint: "~1.20.0-0", ok: []string{"1.20.0-alpha.0", "1.20.3"}, ng: []string{"1.19.9"}},
+	} {
+		t.Run(c.constraint, func(t *testing.T) {
+			constraint, err := ParseConstraint(c.constraint)
+			require.NoError(t, err)
+			require.Equal(t, c.constraint, constraint.String())
+
+			for _, in := range c.ok {
+				v, err := ParseVersion(in)
+				require.NoError(t, err)
+				require.True(t, constraint.Check(v), in)
+			}
+			for _, in := range c.ng {
+				v, err := ParseVersion(in)
+				require.NoError(t, err)
+				require.False(t, constraint.Check(v), in)
+			}
+		})
+	}
+}
+
+func TestParseConstraint_invalid(t *testing.T) {
+	for _, in := range []string{
+		"", "||", "1.18 ||", ">=", "1.18.2.1", ">>1.18", "1.18.a",
+	} {
+		_, err := ParseConstraint(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestGetLatestDistributionMatching(t *testing.T) {
+	ms := &Manifest{
+		IstioDistributions: []*IstioDistribution{
+			{Version: "1.19.0", Flavor: "tetratefips", FlavorVersion: 0},
+			{Version: "1.18.5", Flavor: "tetrate", FlavorVersion: 0},
+			{Version: "1.18.3", Flavor: "tetratefips", FlavorVersion: 2},
+			{Version: "1.18.3", Flavor: "tetratefips", FlavorVersion: 1},
+			{Version: "1.18.1", Flavor: "tetratefips", FlavorVersion: 0},
+		},
+	}
+
+	c, err := ParseConstraint("~1.18")
+	require.NoError(t, err)
+
+	actual, err := GetLatestDistributionMatching(c, "tetratefips", ms)
+	require.NoError(t, err)
+	require.Equal(t, "1.18.3-tetratefips-v2", actual.String())
+
+	actual, err = GetLatestDistributionMatching(c, "istio", ms)
+	require.NoError(t, err)
+	require.Nil(t, actual)
+}
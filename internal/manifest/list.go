@@ -0,0 +1,185 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+
+	"github.com/tetratelabs/getmesh/internal/util"
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+// ListFilter selects the distributions in the manifest. The zero value selects all of them.
+type ListFilter struct {
+	Flavor string
+	// Minor is the Istio minor version in the form of "x.y", e.g. "1.18"
+	Minor string
+	// K8SVersion is the supported k8s version, e.g. "1.26". The patch version is ignored if given.
+	K8SVersion          string
+	SecurityPatchesOnly bool
+	// SupportedOnly excludes the distributions past the end of life at Now. The ones without the end of life are kept.
+	SupportedOnly bool
+	InstalledOnly bool
+	Now           time.Time
+}
+
+// ListedDistribution is the distribution in the manifest along with its local status.
+type ListedDistribution struct {
+	Version         string   `json:"version" yaml:"version"`
+	Flavor          string   `json:"flavor" yaml:"flavor"`
+	FlavorVersion   int64    `json:"flavor_version" yaml:"flavor_version"`
+	K8SVersions     []string `json:"k8s_versions" yaml:"k8s_versions"`
+	EndOfLife       string   `json:"end_of_life,omitempty" yaml:"end_of_life,omitempty"`
+	IsSecurityPatch bool     `json:"is_security_patch" yaml:"is_security_patch"`
+	Active          bool     `json:"active" yaml:"active"`
+	Installed       bool     `json:"installed" yaml:"installed"`
+}
+
+// ListDistributions returns the distributions in the manifest selected by the filter, marking the active and installed ones.
+func ListDistributions(ms *Manifest, current *IstioDistribution, installed []*IstioDistribution,
+	f *ListFilter) ([]*ListedDistribution, error) {
+	minor, err := parseMinorFilter("minor version", f.Minor)
+	if err != nil {
+		return nil, err
+	}
+	k8sMinor, err := parseMinorFilter("k8s version", f.K8SVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*ListedDistribution, 0, len(ms.IstioDistributions))
+	for _, m := range ms.IstioDistributions {
+		d := &ListedDistribution{
+			Version:         m.Version,
+			Flavor:          m.Flavor,
+			FlavorVersion:   m.FlavorVersion,
+			K8SVersions:     m.K8SVersions,
+			EndOfLife:       m.EndOfLife,
+			IsSecurityPatch: m.IsSecurityPatch,
+			Active:          current != nil && m.Equal(current),
+		}
+		for _, i := range installed {
+			if m.Equal(i) {
+				d.Installed = true
+				break
+			}
+		}
+
+		if len(f.Flavor) != 0 && m.Flavor != f.Flavor {
+			continue
+		}
+		if len(minor) != 0 && !strings.HasPrefix(m.Version, minor+".") {
+			continue
+		}
+		if len(k8sMinor) != 0 && !containsString(m.K8SVersions, k8sMinor) {
+			continue
+		}
+		if f.SecurityPatchesOnly && !m.IsSecurityPatch {
+			continue
+		}
+		if f.InstalledOnly && !d.Installed {
+			continue
+		}
+		if f.SupportedOnly && len(m.EndOfLife) != 0 {
+			eol, err := parseManifestEOLDate(m.EndOfLife)
+			if err != nil {
+				return nil, fmt.Errorf("invalid end of life %s of %s: %v", m.EndOfLife, m.String(), err)
+			}
+			if f.Now.After(eol) {
+				continue
+			}
+		}
+		ret = append(ret, d)
+	}
+	return ret, nil
+}
+
+// parse "x.y" or "x.y.z" into "x.y"
+func parseMinorFilter(name, in string) (string, error) {
+	if len(in) == 0 {
+		return "", nil
+	}
+
+	ts := strings.Split(in, ".")
+	if len(ts) != 2 && len(ts) != 3 {
+		return "", fmt.Errorf("invalid %s %s: must be in the form of 'x.y'", name, in)
+	}
+	for _, t := range ts {
+		if _, err := strconv.Atoi(t); err != nil {
+			return "", fmt.Errorf("invalid %s %s: must be in the form of 'x.y'", name, in)
+		}
+	}
+	return ts[0] + "." + ts[1], nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintDistributions prints the distributions in the format, "table", "wide", "json" or "yaml".
+// The active one is marked with "*" in the table, and "wide" adds the security patch and installed columns.
+func PrintDistributions(ds []*ListedDistribution, format string) error {
+	switch format {
+	case "json":
+		raw, err := json.MarshalIndent(ds, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling distributions: %v", err)
+		}
+		logger.Outputf("%s\n", raw)
+	case "yaml":
+		raw, err := yaml.Marshal(ds)
+		if err != nil {
+			return fmt.Errorf("error marshaling distributions: %v", err)
+		}
+		logger.Outputf("%s", raw)
+	case "table", "wide":
+		column := []string{"ISTIO VERSION", "FLAVOR", "FLAVOR VERSION", "K8S VERSIONS", "END OF LIFE"}
+		if format == "wide" {
+			column = append(column, "SECURITY PATCH", "INSTALLED")
+		}
+
+		data := make([][]string, len(ds))
+		for i, d := range ds {
+			version := d.Version
+			if d.Active {
+				version = "*" + version
+			}
+			data[i] = []string{version, d.Flavor,
+				strconv.Itoa(int(d.FlavorVersion)), strings.Join(d.K8SVersions, ","), d.EndOfLife}
+			if format == "wide" {
+				data[i] = append(data[i], strconv.FormatBool(d.IsSecurityPatch), strconv.FormatBool(d.Installed))
+			}
+		}
+
+		table := tablewriter.NewWriter(logger.GetWriter())
+		table.SetHeader(column)
+		util.FlushTable(table, data)
+	default:
+		return fmt.Errorf("unsupported output format %s: must be one of table, wide, json or yaml", format)
+	}
+	return nil
+}
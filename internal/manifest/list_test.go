@@ -0,0 +1,154 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+func TestListDistributions(t *testing.T) {
+	ms := &Manifest{
+		IstioDistributions: []*IstioDistribution{
+			{Version: "1.18.2", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0,
+				K8SVersions: []string{"1.25", "1.26"}, EndOfLife: "2024-01-03", IsSecurityPatch: true},
+			{Version: "1.18.1", Flavor: IstioDistributionFlavorTetrateFIPS, FlavorVersion: 0,
+				K8SVersions: []string{"1.25", "1.26"}, EndOfLife: "2024-01-03"},
+			{Version: "1.17.3", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 1,
+				K8SVersions: []string{"1.24", "1.25"}, EndOfLife: "2023-10-27"},
+			{Version: "1.1.0", Flavor: IstioDistributionFlavorIstio, FlavorVersion: 0,
+				K8SVersions: []string{"1.13"}},
+		},
+	}
+	current := &IstioDistribution{Version: "1.18.1", Flavor: IstioDistributionFlavorTetrateFIPS, FlavorVersion: 0}
+	installed := []*IstioDistribution{
+		{Version: "1.18.1", Flavor: IstioDistributionFlavorTetrateFIPS, FlavorVersion: 0},
+		{Version: "1.17.3", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 1},
+	}
+	now := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	versions := func(ds []*ListedDistribution) []string {
+		ret := make([]string, len(ds))
+		for i, d := range ds {
+			ret[i] = d.Version
+		}
+		return ret
+	}
+
+	for _, c := range []struct {
+		name   string
+		filter ListFilter
+		exp    []string
+	}{
+		{name: "all", exp: []string{"1.18.2", "1.18.1", "1.17.3", "1.1.0"}},
+		{name: "flavor", filter: ListFilter{Flavor: "tetrate"}, exp: []string{"1.18.2", "1.17.3"}},
+		{name: "minor", filter: ListFilter{Minor: "1.1"}, exp: []string{"1.1.0"}},
+		{name: "minor with patch", filter: ListFilter{Minor: "1.18.0"}, exp: []string{"1.18.2", "1.18.1"}},
+		{name: "k8s", filter: ListFilter{K8SVersion: "1.24"}, exp: []string{"1.17.3"}},
+		{name: "k8s with patch", filter: ListFilter{K8SVersion: "1.26.3"}, exp: []string{"1.18.2", "1.18.1"}},
+		{name: "security patches", filter: ListFilter{SecurityPatchesOnly: true}, exp: []string{"1.18.2"}},
+		{name: "supported", filter: ListFilter{SupportedOnly: true, Now: now}, exp: []string{"1.18.2", "1.18.1", "1.1.0"}},
+		{name: "installed", filter: ListFilter{InstalledOnly: true}, exp: []string{"1.18.1", "1.17.3"}},
+		{name: "combined", filter: ListFilter{InstalledOnly: true, SupportedOnly: true, Now: now}, exp: []string{"1.18.1"}},
+		{name: "none", filter: ListFilter{Flavor: "tetrate", Minor: "1.1"}, exp: []string{}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := ListDistributions(ms, current, installed, &c.filter)
+			require.NoError(t, err)
+			require.Equal(t, c.exp, versions(actual))
+		})
+	}
+
+	t.Run("status", func(t *testing.T) {
+		actual, err := ListDistributions(ms, current, installed, &ListFilter{Minor: "1.18"})
+		require.NoError(t, err)
+		require.Equal(t, []*ListedDistribution{
+			{Version: "1.18.2", Flavor: "tetrate", K8SVersions: []string{"1.25", "1.26"}, EndOfLife: "2024-01-03",
+				IsSecurityPatch: true},
+			{Version: "1.18.1", Flavor: "tetratefips", K8SVersions: []string{"1.25", "1.26"}, EndOfLife: "2024-01-03",
+				Active: true, Installed: true},
+		}, actual)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, f := range []ListFilter{{Minor: "1"}, {Minor: "1.x"}, {K8SVersion: "v1.26"}} {
+			_, err := ListDistributions(ms, nil, nil, &f)
+			require.Error(t, err)
+		}
+	})
+}
+
+func TestPrintDistributions(t *testing.T) {
+	ds := []*ListedDistribution{
+		{Version: "1.18.2", Flavor: "tetrate", K8SVersions: []string{"1.26"}, EndOfLife: "2024-01-03",
+			IsSecurityPatch: true},
+		{Version: "1.18.1", Flavor: "tetrate", K8SVersions: []string{"1.26"}, EndOfLife: "2024-01-03",
+			Active: true, Installed: true},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintDistributions(ds, "table"))
+		})
+		// the security patch and the installed columns are only in the wide table
+		require.Equal(t, `ISTIO VERSION	FLAVOR 	FLAVOR VERSION	K8S VERSIONS	END OF LIFE 
+   1.18.2    	tetrate	      0       	    1.26    	2024-01-03 	
+   *1.18.1   	tetrate	      0       	    1.26    	2024-01-03 	
+`, buf.String())
+	})
+
+	t.Run("wide", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintDistributions(ds, "wide"))
+		})
+		require.Equal(t, `ISTIO VERSION	FLAVOR 	FLAVOR VERSION	K8S VERSIONS	END OF LIFE	SECURITY PATCH	INSTALLED 
+   1.18.2    	tetrate	      0       	    1.26    	2024-01-03 	     true     	  false  	
+   *1.18.1   	tetrate	      0       	    1.26    	2024-01-03 	    false     	  true   	
+`, buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintDistributions(ds[1:], "json"))
+		})
+		require.JSONEq(t, `[{"version":"1.18.1","flavor":"tetrate","flavor_version":0,"k8s_versions":["1.26"],
+"end_of_life":"2024-01-03","is_security_patch":false,"active":true,"installed":true}]`, buf.String())
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		buf := logger.ExecuteWithLock(func() {
+			require.NoError(t, PrintDistributions(ds[:1], "yaml"))
+		})
+		require.Equal(t, `- version: 1.18.2
+  flavor: tetrate
+  flavor_version: 0
+  k8s_versions:
+    - "1.26"
+  end_of_life: "2024-01-03"
+  is_security_patch: true
+  active: false
+  installed: false
+`, buf.String())
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		require.Error(t, PrintDistributions(ds, "xml"))
+	})
+}
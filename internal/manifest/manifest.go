@@ -15,58 +15,133 @@
 package manifest
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
-	"strings"
+	"path/filepath"
 	"sync"
+	"time"
 
-	"github.com/olekukonko/tablewriter"
-
-	"github.com/tetratelabs/getmesh/internal/util/logger"
+	"github.com/tetratelabs/getmesh/internal/util"
+	"github.com/tetratelabs/getmesh/internal/util/httpclient"
 )
 
 const (
 	manifestURL = "https://istio.tetratelabs.io/getmesh/manifest.json"
+
+	defaultSourceTimeout = 30 * time.Second
 )
 
+// Source is a location from which the manifest is fetched.
+type Source struct {
+	// URL of the manifest. "https://", "http://" and "file://" schemes are supported.
+	URL string `json:"url"`
+	// Timeout for fetching the manifest from this source, e.g. "10s". Defaults to 30s.
+	Timeout string `json:"timeout,omitempty"`
+	// SignatureURL of the detached ed25519 signature of the manifest. Defaults to URL with ".sig" suffix.
+	SignatureURL string `json:"signature_url,omitempty"`
+}
+
+// the sources tried in order by FetchManifest
+var sources = []Source{{URL: manifestURL}}
+
 // GlobalManifestURLMux for test purpose
 var GlobalManifestURLMux sync.Mutex
 
-func FetchManifest() (ret *Manifest, err error) {
-	if p := os.Getenv("GETMESH_TEST_MANIFEST_PATH"); len(p) != 0 {
-		raw, err := ioutil.ReadFile(p)
-		if err != nil {
-			return nil, err
-		}
+// SetSources replaces the manifest sources used by FetchManifest. An empty slice resets them to the default.
+func SetSources(in []Source) error {
+	if len(in) == 0 {
+		sources = []Source{{URL: manifestURL}}
+		return nil
+	}
 
-		if err := json.Unmarshal(raw, &ret); err != nil {
-			return nil, fmt.Errorf("error unmarshalling fetched manifest: %v", err)
+	for _, s := range in {
+		if _, err := s.timeout(); err != nil {
+			return err
 		}
-	} else {
-		ret, err = fetchManifest(manifestURL)
-		if err != nil {
-			return nil, err
+		urls := []string{s.URL}
+		if len(s.SignatureURL) != 0 {
+			urls = append(urls, s.SignatureURL)
+		}
+		for _, raw := range urls {
+			u, err := url.Parse(raw)
+			if err != nil {
+				return fmt.Errorf("invalid manifest source %s: %v", raw, err)
+			}
+			switch u.Scheme {
+			case "https", "http", "file":
+			default:
+				return fmt.Errorf("invalid manifest source %s: unsupported scheme %q", raw, u.Scheme)
+			}
 		}
 	}
-	return
+	sources = in
+	return nil
 }
 
-func fetchManifest(url string) (*Manifest, error) {
-	res, err := http.Get(url)
+// GetSources returns the manifest sources used by FetchManifest.
+func GetSources() []Source {
+	return sources
+}
+
+func (s Source) timeout() (time.Duration, error) {
+	if len(s.Timeout) == 0 {
+		return defaultSourceTimeout, nil
+	}
+	d, err := time.ParseDuration(s.Timeout)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching manifest: %v", err)
+		return 0, fmt.Errorf("invalid timeout %s for manifest source %s: %v", s.Timeout, s.URL, err)
 	}
+	return d, nil
+}
 
-	defer res.Body.Close()
-	raw, err := ioutil.ReadAll(res.Body)
+func (s Source) signatureURL() string {
+	if len(s.SignatureURL) != 0 {
+		return s.SignatureURL
+	}
+	return s.URL + signatureSuffix
+}
+
+func FetchManifest() (*Manifest, error) {
+	if p := os.Getenv("GETMESH_TEST_MANIFEST_PATH"); len(p) != 0 {
+		return readTestManifest(p)
+	}
+
+	raw, err := loadManifest(sources)
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(raw)
+}
+
+// the test manifest is verified against the signature at the ".sig" suffixed path like the other sources
+func readTestManifest(path string) (*Manifest, error) {
+	raw, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("error reading fetched manifest: %v ", err)
+		return nil, err
+	}
+
+	sig, err := ioutil.ReadFile(path + signatureSuffix)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading manifest signature: %v", err)
+	}
+	if err := verifySignature(raw, sig); err != nil {
+		return nil, err
 	}
 
+	var ret Manifest
+	if err := json.Unmarshal(raw, &ret); err != nil {
+		return nil, fmt.Errorf("error unmarshalling fetched manifest: %v", err)
+	}
+	return &ret, nil
+}
+
+func parseManifest(raw []byte) (*Manifest, error) {
 	var ret Manifest
 	if err := json.Unmarshal(raw, &ret); err != nil {
 		return nil, fmt.Errorf("error unmarshalling fetched manifest: %v", err)
@@ -79,37 +154,118 @@ func fetchManifest(url string) (*Manifest, error) {
 	return &ret, nil
 }
 
-func PrintManifest(ms *Manifest, current *IstioDistribution) error {
-	column := []string{"ISTIO VERSION", "FLAVOR", "FLAVOR VERSION", "K8S VERSIONS", "END OF LIFE"}
-	data := make([][]string, len(ms.IstioDistributions))
-	for i, m := range ms.IstioDistributions {
-		ps := strings.Join(m.K8SVersions, ",")
-		if current != nil && m.Equal(current) {
-			m.Version = "*" + m.Version
+type fetchResult struct {
+	source       string
+	raw          []byte
+	signature    []byte
+	etag         string
+	lastModified string
+	// set when the source responded with 304 Not Modified against the cached manifest
+	notModified bool
+}
+
+// try the given sources in order and return the first manifest successfully fetched.
+// When cached is non-nil, a conditional request is made to the source the cache came from.
+func fetchManifestFromSources(ss []Source, cached *cacheMetadata) (*fetchResult, error) {
+	errs := make([]error, 0, len(ss))
+	for _, s := range ss {
+		var etag, lastModified string
+		if cached != nil && cached.Source == s.URL {
+			etag, lastModified = cached.ETag, cached.LastModified
+		}
+
+		res, err := readSource(s, etag, lastModified)
+		if err == nil && !res.notModified {
+			_, err = parseManifest(res.raw)
 		}
-		data[i] = []string{m.Version, m.Flavor,
-			strconv.Itoa(int(m.FlavorVersion)), ps, m.EndOfLife}
+		if err == nil {
+			return res, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %v", s.URL, err))
+	}
+	return nil, fmt.Errorf("error fetching manifest from all sources: %v", util.HandleMultipleErrors(errs))
+}
+
+// read the manifest and verify it against its detached signature
+func readSource(s Source, etag, lastModified string) (*fetchResult, error) {
+	res, err := readURL(s, s.URL, etag, lastModified)
+	if err != nil || res.notModified || skipVerify {
+		return res, err
 	}
 
-	table := tablewriter.NewWriter(logger.GetWriter())
-	table.SetHeader(column)
-	flushTable(table, data)
-	return nil
+	// the signature is only fetched when there is a key to verify it with
+	if ok, err := hasTrustedPublicKey(); err != nil {
+		return nil, err
+	} else if ok {
+		sig, err := readURL(s, s.signatureURL(), "", "")
+		if err != nil {
+			return nil, fmt.Errorf("error fetching manifest signature: %v", err)
+		}
+		res.signature = sig.raw
+	}
+
+	if err := verifySignature(res.raw, res.signature); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
-func flushTable(table *tablewriter.Table, data [][]string) {
-	table.SetAutoWrapText(true)
-	table.SetColWidth(tablewriter.MAX_ROW_WIDTH * 4)
-	table.SetAutoFormatHeaders(true)
-	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
-	table.SetAlignment(tablewriter.ALIGN_CENTER)
-	table.SetCenterSeparator("")
-	table.SetColumnSeparator("")
-	table.SetRowSeparator("")
-	table.SetHeaderLine(false)
-	table.SetBorder(false)
-	table.SetTablePadding("\t") // pad with tabs
-	table.SetNoWhiteSpace(true)
-	table.AppendBulk(data) // Add Bulk Data
-	table.Render()
+func readURL(s Source, rawURL, etag, lastModified string) (*fetchResult, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %v", rawURL, err)
+	}
+
+	if u.Scheme == "file" {
+		raw, err := ioutil.ReadFile(filepath.FromSlash(u.Host + u.Path))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", rawURL, err)
+		}
+		return &fetchResult{source: s.URL, raw: raw}, nil
+	}
+
+	timeout, err := s.timeout()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	if len(etag) != 0 {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if len(lastModified) != 0 {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	res, err := httpclient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", rawURL, err)
+	}
+
+	defer res.Body.Close()
+	ret := &fetchResult{
+		source:       s.URL,
+		etag:         res.Header.Get("ETag"),
+		lastModified: res.Header.Get("Last-Modified"),
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		ret.notModified = true
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("error fetching %s: unexpected status %s", rawURL, res.Status)
+	}
+
+	ret.raw, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v ", rawURL, err)
+	}
+	return ret, nil
 }
@@ -15,14 +15,18 @@
 package manifest
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
-	"github.com/tetratelabs/getmesh/internal/util/logger"
+	"github.com/tetratelabs/getmesh/internal/test"
 )
 
 func TestFetchManifest(t *testing.T) {
@@ -54,12 +58,12 @@ func TestFetchManifest(t *testing.T) {
 	raw, err := json.Marshal(manifest)
 	require.NoError(t, err)
 
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write(raw)
-	}))
+	ts := httptest.NewServer(signedHandler(trustTestKey(t), raw))
 	defer ts.Close()
 
-	actual, err := fetchManifest(ts.URL)
+	res, err := fetchManifestFromSources([]Source{{URL: ts.URL + "/manifest.json"}}, nil)
+	require.NoError(t, err)
+	actual, err := parseManifest(res.raw)
 	require.NoError(t, err)
 
 	expIstioVersions := map[string]struct{}{
@@ -75,79 +79,114 @@ func TestFetchManifest(t *testing.T) {
 	require.Equal(t, map[string]struct{}{}, expIstioVersions)
 }
 
-func TestPrintManifest(t *testing.T) {
-	t.Run("nil-current", func(t *testing.T) {
-		manifest := &Manifest{
-			IstioDistributions: []*IstioDistribution{
-				{
-					Version:       "1.7.6",
-					Flavor:        IstioDistributionFlavorTetrate,
-					FlavorVersion: 0,
-					K8SVersions:   []string{"1.16"},
-					EndOfLife:     "2022-01-01",
-				},
-				{
-					Version:       "1.7.5",
-					Flavor:        IstioDistributionFlavorTetrate,
-					FlavorVersion: 0,
-					K8SVersions:   []string{"1.16"},
-					EndOfLife:     "2022-01-01",
-				},
-			},
-		}
-
-		buf := logger.ExecuteWithLock(func() {
-			require.NoError(t, PrintManifest(manifest, nil))
-		})
-		require.Equal(t, `ISTIO VERSION	FLAVOR 	FLAVOR VERSION	K8S VERSIONS	END OF LIFE 
-    1.7.6    	tetrate	      0       	    1.16    	2022-01-01 	
-    1.7.5    	tetrate	      0       	    1.16    	2022-01-01 	
-`,
-			buf.String())
+func TestFetchManifest_testManifestPath(t *testing.T) {
+	GlobalManifestURLMux.Lock()
+	defer GlobalManifestURLMux.Unlock()
+
+	raw := []byte(`{"istio_distributions":[{"version":"1.7.6","flavor":"tetrate","flavor_version":0}]}`)
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	require.NoError(t, os.WriteFile(path, raw, 0644))
+	t.Setenv("GETMESH_TEST_MANIFEST_PATH", path)
+	priv := trustTestKey(t)
+
+	t.Run("signed", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path+signatureSuffix, ed25519.Sign(priv, raw), 0644))
+		defer os.Remove(path + signatureSuffix)
+		actual, err := FetchManifest()
+		require.NoError(t, err)
+		require.Len(t, actual.IstioDistributions, 1)
 	})
 
-	t.Run("non-nil-current", func(t *testing.T) {
-		current := &IstioDistribution{
-			Version:       "1.7.6",
-			Flavor:        IstioDistributionFlavorTetrateFIPS,
-			FlavorVersion: 0,
-			K8SVersions:   []string{"1.16"},
-		}
-		manifest := &Manifest{
-			IstioDistributions: []*IstioDistribution{
-				{
-					Version:       "1.8.3",
-					Flavor:        IstioDistributionFlavorIstio,
-					FlavorVersion: 0,
-					K8SVersions:   []string{"1.18"},
-					EndOfLife:     "2023-01-01",
-				},
-				{
-					Version:       "1.7.6",
-					Flavor:        IstioDistributionFlavorTetrateFIPS,
-					FlavorVersion: 0,
-					K8SVersions:   []string{"1.16"},
-					EndOfLife:     "2022-01-01",
-				},
-				{
-					Version:       "1.7.5",
-					Flavor:        IstioDistributionFlavorTetrate,
-					FlavorVersion: 0,
-					K8SVersions:   []string{"1.16"},
-					EndOfLife:     "2022-01-01",
-				},
-			},
-		}
-
-		buf := logger.ExecuteWithLock(func() {
-			require.NoError(t, PrintManifest(manifest, current))
-		})
-
-		require.Equal(t, `ISTIO VERSION	  FLAVOR   	FLAVOR VERSION	K8S VERSIONS	END OF LIFE 
-    1.8.3    	   istio   	      0       	    1.18    	2023-01-01 	
-   *1.7.6    	tetratefips	      0       	    1.16    	2022-01-01 	
-    1.7.5    	  tetrate  	      0       	    1.16    	2022-01-01 	
-`,
-			buf.String())
+	t.Run("tampered", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path+signatureSuffix, ed25519.Sign(priv, []byte("other")), 0644))
+		defer os.Remove(path + signatureSuffix)
+		_, err := FetchManifest()
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		_, err := FetchManifest()
+		require.ErrorIs(t, err, ErrSignatureNotPublished)
+	})
+}
+
+func Test_fetchManifestFromSources(t *testing.T) {
+	manifest := &Manifest{
+		IstioDistributions: []*IstioDistribution{
+			{Version: "1.7.6", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+		},
+	}
+
+	raw, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	priv := trustTestKey(t)
+	ok := httptest.NewServer(signedHandler(priv, raw))
+	defer ok.Close()
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>captive portal</html>"))
+	}))
+	defer broken.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		signedHandler(priv, raw)(w, r)
+	}))
+	defer slow.Close()
+
+	f := test.TempFile(t, "", "")
+	_, err = f.Write(raw)
+	require.NoError(t, err)
+	sig := test.TempFile(t, "", "")
+	_, err = sig.Write(ed25519.Sign(priv, raw))
+	require.NoError(t, err)
+
+	t.Run("failover", func(t *testing.T) {
+		res, err := fetchManifestFromSources([]Source{
+			{URL: unavailable.URL + "/manifest.json"},
+			{URL: broken.URL + "/manifest.json"},
+			{URL: slow.URL + "/manifest.json", Timeout: "10ms"},
+			{URL: ok.URL + "/manifest.json"},
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, ok.URL+"/manifest.json", res.source)
+		require.Equal(t, raw, res.raw)
 	})
+
+	t.Run("file", func(t *testing.T) {
+		res, err := fetchManifestFromSources([]Source{{URL: "file://" + f.Name(), SignatureURL: "file://" + sig.Name()}}, nil)
+		require.NoError(t, err)
+		require.Equal(t, raw, res.raw)
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		_, err := fetchManifestFromSources([]Source{
+			{URL: unavailable.URL},
+			{URL: "file:///non-existent/manifest.json"},
+		}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), unavailable.URL)
+		require.Contains(t, err.Error(), "file:///non-existent/manifest.json")
+	})
+}
+
+func TestSetSources(t *testing.T) {
+	GlobalManifestURLMux.Lock()
+	defer GlobalManifestURLMux.Unlock()
+	defer func() { require.NoError(t, SetSources(nil)) }()
+
+	require.NoError(t, SetSources([]Source{{URL: "https://example.com/manifest.json", Timeout: "5s"}, {URL: "file:///tmp/manifest.json"}}))
+	require.Len(t, GetSources(), 2)
+
+	require.Error(t, SetSources([]Source{{URL: "ftp://example.com/manifest.json"}}))
+	require.Error(t, SetSources([]Source{{URL: "https://example.com/manifest.json", Timeout: "five"}}))
+
+	require.NoError(t, SetSources(nil))
+	require.Equal(t, []Source{{URL: manifestURL}}, GetSources())
 }
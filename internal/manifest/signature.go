@@ -0,0 +1,171 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const signatureSuffix = ".sig"
+
+var (
+	// builtinPublicKey is the base64 encoded ed25519 public key of the official manifest, set via
+	// -ldflags "-X github.com/tetratelabs/getmesh/internal/manifest.builtinPublicKey=..." once the signature is published
+	builtinPublicKey string
+
+	// the keys trusted in addition to the built-in one
+	extraPublicKeys []ed25519.PublicKey
+	skipVerify      bool
+	// requireSignature rejects the manifest when no key is trusted. Otherwise it is accepted with the warning.
+	// Once any key is trusted, the manifest without the valid signature is always rejected.
+	requireSignature bool
+
+	// the warning is written to stderr so that the json and yaml outputs are kept parsable
+	unverifiedWarnWriter io.Writer = os.Stderr
+	unverifiedWarnOnce   sync.Once
+
+	ErrNoTrustedPublicKey = errors.New("no public key is trusted for manifest verification. " +
+		"Please add one to \"manifest_public_keys\" in config.json, or use --insecure-skip-manifest-verify at your own risk")
+	ErrSignatureNotPublished = errors.New("the manifest signature is not published. " +
+		"Please check the manifest sources, or use --insecure-skip-manifest-verify at your own risk")
+	ErrSignatureMismatch = errors.New("manifest signature verification failed: the manifest is not signed by any trusted key. " +
+		"Please check the manifest sources, or use --insecure-skip-manifest-verify at your own risk")
+)
+
+// SetPublicKeys sets the base64 encoded ed25519 public keys trusted in addition to the built-in one.
+func SetPublicKeys(in []string) error {
+	keys := make([]ed25519.PublicKey, 0, len(in))
+	for _, k := range in {
+		key, err := parsePublicKey(k)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	extraPublicKeys = keys
+	return nil
+}
+
+// SetInsecureSkipVerify disables the manifest signature verification.
+func SetInsecureSkipVerify(b bool) {
+	skipVerify = b
+}
+
+// SetRequireSignature makes the manifest fail the verification when no key is trusted.
+func SetRequireSignature(b bool) {
+	requireSignature = b
+}
+
+func parsePublicKey(in string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(in)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest public key %s: %v", in, err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid manifest public key %s: expected %d bytes but got %d",
+			in, ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+func trustedPublicKeys() ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(extraPublicKeys)+1)
+	if len(builtinPublicKey) != 0 {
+		key, err := parsePublicKey(builtinPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid built-in key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return append(keys, extraPublicKeys...), nil
+}
+
+// the signature is either the raw 64 bytes or its base64 encoding
+func decodeSignature(in []byte) ([]byte, error) {
+	if len(in) == ed25519.SignatureSize {
+		return in, nil
+	}
+
+	ret, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(in)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest signature: %v", err)
+	}
+	if len(ret) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid manifest signature: expected %d bytes but got %d", ed25519.SignatureSize, len(ret))
+	}
+	return ret, nil
+}
+
+// verifySignature returns nil if the manifest is signed by any of the trusted keys.
+// Unless the signature is required, the absence of trusted keys is only warned.
+func verifySignature(raw, signature []byte) error {
+	err := checkSignature(raw, signature)
+	if acceptUnverified(err) {
+		warnUnverified(err)
+		return nil
+	}
+	return err
+}
+
+func checkSignature(raw, signature []byte) error {
+	if skipVerify {
+		return nil
+	}
+
+	keys, err := trustedPublicKeys()
+	if err != nil {
+		return err
+	} else if len(keys) == 0 {
+		return ErrNoTrustedPublicKey
+	} else if len(signature) == 0 {
+		return ErrSignatureNotPublished
+	}
+
+	sig, err := decodeSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if ed25519.Verify(key, raw, sig) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+// the manifest is accepted without any trusted key unless the signature is required, while the one without
+// the valid signature is always rejected once a key is trusted, so that blocking the signature does not bypass it
+func acceptUnverified(err error) bool {
+	return !requireSignature && errors.Is(err, ErrNoTrustedPublicKey)
+}
+
+func hasTrustedPublicKey() (bool, error) {
+	keys, err := trustedPublicKeys()
+	return len(keys) != 0, err
+}
+
+func warnUnverified(err error) {
+	unverifiedWarnOnce.Do(func() {
+		fmt.Fprintf(unverifiedWarnWriter, "[WARNING] the manifest is not verified: %v\n", err)
+	})
+}
@@ -0,0 +1,235 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// generate a key pair trusted during the test
+func trustTestKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, SetPublicKeys([]string{base64.StdEncoding.EncodeToString(pub)}))
+	t.Cleanup(func() { require.NoError(t, SetPublicKeys(nil)) })
+	return priv
+}
+
+// require the signature during the test, which is otherwise optional
+func requireTestSignature(t *testing.T) {
+	t.Helper()
+	SetRequireSignature(true)
+	t.Cleanup(func() { SetRequireSignature(false) })
+}
+
+// capture the warning on the unverified manifest during the test
+func captureUnverifiedWarning(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	unverifiedWarnWriter, unverifiedWarnOnce = buf, sync.Once{}
+	t.Cleanup(func() { unverifiedWarnWriter, unverifiedWarnOnce = os.Stderr, sync.Once{} })
+	return buf
+}
+
+// serve the raw manifest with its base64 encoded signature at the ".sig" suffixed path
+func signedHandler(priv ed25519.PrivateKey, raw []byte) http.HandlerFunc {
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, raw))
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, signatureSuffix) {
+			_, _ = w.Write([]byte(sig + "\n"))
+			return
+		}
+		_, _ = w.Write(raw)
+	}
+}
+
+func Test_verifySignature(t *testing.T) {
+	raw := []byte(`{"istio_distributions":[]}`)
+
+	t.Run("no trusted key", func(t *testing.T) {
+		buf := captureUnverifiedWarning(t)
+		require.NoError(t, verifySignature(raw, nil))
+		require.Contains(t, buf.String(), "[WARNING] the manifest is not verified: no public key is trusted")
+
+		requireTestSignature(t)
+		require.Equal(t, ErrNoTrustedPublicKey, verifySignature(raw, nil))
+	})
+
+	priv := trustTestKey(t)
+	sig := ed25519.Sign(priv, raw)
+
+	t.Run("not published", func(t *testing.T) {
+		buf := captureUnverifiedWarning(t)
+		// the trusted key requires the signature even if it is not required in config.json
+		require.Equal(t, ErrSignatureNotPublished, verifySignature(raw, nil))
+		require.Empty(t, buf.String())
+	})
+
+	t.Run("raw signature", func(t *testing.T) {
+		require.NoError(t, verifySignature(raw, sig))
+	})
+
+	t.Run("base64 signature", func(t *testing.T) {
+		require.NoError(t, verifySignature(raw, []byte(base64.StdEncoding.EncodeToString(sig)+"\n")))
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		require.Equal(t, ErrSignatureMismatch, verifySignature([]byte(`{"istio_distributions":[{}]}`), sig))
+	})
+
+	t.Run("untrusted key", func(t *testing.T) {
+		_, other, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		require.Equal(t, ErrSignatureMismatch, verifySignature(raw, ed25519.Sign(other, raw)))
+	})
+
+	t.Run("malformed signature", func(t *testing.T) {
+		require.Error(t, verifySignature(raw, []byte("not a signature")))
+	})
+
+	t.Run("built-in key", func(t *testing.T) {
+		pub, other, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		builtinPublicKey = base64.StdEncoding.EncodeToString(pub)
+		defer func() { builtinPublicKey = "" }()
+
+		require.NoError(t, verifySignature(raw, ed25519.Sign(other, raw)))
+		// the extra keys are still trusted
+		require.NoError(t, verifySignature(raw, sig))
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		requireTestSignature(t)
+		SetInsecureSkipVerify(true)
+		defer SetInsecureSkipVerify(false)
+		require.NoError(t, verifySignature([]byte("tampered"), nil))
+	})
+}
+
+func TestSetPublicKeys(t *testing.T) {
+	defer func() { require.NoError(t, SetPublicKeys(nil)) }()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, SetPublicKeys([]string{base64.StdEncoding.EncodeToString(pub)}))
+	require.Len(t, extraPublicKeys, 1)
+
+	require.Error(t, SetPublicKeys([]string{"not base64"}))
+	require.Error(t, SetPublicKeys([]string{base64.StdEncoding.EncodeToString([]byte("short"))}))
+}
+
+func Test_readSource_signature(t *testing.T) {
+	raw := []byte(`{"istio_distributions":[]}`)
+	priv := trustTestKey(t)
+
+	signed := httptest.NewServer(signedHandler(priv, raw))
+	defer signed.Close()
+
+	tampered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, signatureSuffix) {
+			signedHandler(priv, raw)(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"istio_distributions":[{"version":"6.6.6"}]}`))
+	}))
+	defer tampered.Close()
+
+	unsigned := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, signatureSuffix) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(raw)
+	}))
+	defer unsigned.Close()
+
+	t.Run("ok", func(t *testing.T) {
+		res, err := readSource(Source{URL: signed.URL + "/manifest.json"}, "", "")
+		require.NoError(t, err)
+		require.Equal(t, raw, res.raw)
+	})
+
+	t.Run("signature url", func(t *testing.T) {
+		res, err := readSource(Source{URL: unsigned.URL + "/manifest.json", SignatureURL: signed.URL + "/manifest.json.sig"}, "", "")
+		require.NoError(t, err)
+		require.Equal(t, raw, res.raw)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		_, err := readSource(Source{URL: tampered.URL + "/manifest.json"}, "", "")
+		require.Equal(t, ErrSignatureMismatch, err)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		_, err := readSource(Source{URL: unsigned.URL + "/manifest.json"}, "", "")
+		require.Contains(t, err.Error(), "error fetching manifest signature")
+
+		SetInsecureSkipVerify(true)
+		defer SetInsecureSkipVerify(false)
+		res, err := readSource(Source{URL: unsigned.URL + "/manifest.json"}, "", "")
+		require.NoError(t, err)
+		require.Equal(t, raw, res.raw)
+	})
+
+	t.Run("signature unavailable", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, signatureSuffix) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write(raw)
+		}))
+		defer failing.Close()
+
+		_, err := readSource(Source{URL: failing.URL + "/manifest.json"}, "", "")
+		require.Contains(t, err.Error(), "error fetching manifest signature")
+
+		// the unreachable signature is not taken as the unpublished one
+		_, err = readSource(Source{URL: signed.URL + "/manifest.json", SignatureURL: "http://127.0.0.1:0/manifest.json.sig"}, "", "")
+		require.Contains(t, err.Error(), "error fetching manifest signature")
+		require.NotErrorIs(t, err, ErrSignatureNotPublished)
+	})
+
+	t.Run("no trusted key", func(t *testing.T) {
+		require.NoError(t, SetPublicKeys(nil))
+		defer func() {
+			require.NoError(t, SetPublicKeys([]string{base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey))}))
+		}()
+
+		// the signature is not fetched without any key to verify it with
+		buf := captureUnverifiedWarning(t)
+		res, err := readSource(Source{URL: unsigned.URL + "/manifest.json"}, "", "")
+		require.NoError(t, err)
+		require.Equal(t, raw, res.raw)
+		require.Nil(t, res.signature)
+		require.Contains(t, buf.String(), "no public key is trusted")
+
+		requireTestSignature(t)
+		_, err = readSource(Source{URL: unsigned.URL + "/manifest.json"}, "", "")
+		require.ErrorIs(t, err, ErrNoTrustedPublicKey)
+	})
+}
@@ -15,12 +15,15 @@
 package manifest
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
-	"github.com/Masterminds/semver"
+	"github.com/Masterminds/semver/v3"
 )
 
 type Manifest struct {
@@ -29,6 +32,9 @@ type Manifest struct {
 	// key: "x.y", "1.7" for example
 	// value: "YYYY-MM-DD"
 	IstioMinorVersionsEOLDates map[string]string `json:"istio_minor_versions_eol_dates"`
+	// ArtifactURLTemplate is the default for the distributions without their own artifact URLs.
+	// See IstioDistribution.ArtifactURLTemplate for the syntax.
+	ArtifactURLTemplate string `json:"artifact_url_template,omitempty"`
 }
 
 type IstioDistribution struct {
@@ -46,6 +52,31 @@ type IstioDistribution struct {
 	ReleaseNotes []string `json:"release_notes,omitempty"`
 	// EndOfLife of this distribution (format: "YYYY-MM-DD")
 	EndOfLife string `json:"end_of_life,omitempty"`
+	// Artifacts are the release archives of this distribution per platform.
+	Artifacts []*Artifact `json:"artifacts,omitempty"`
+	// ArtifactURLTemplate is the text/template of the archive URL used for the platforms without artifact URLs.
+	// Available fields are .Distribution, .Version, .Flavor, .FlavorVersion, .OS and .Arch,
+	// e.g. "https://mirror.example.com/istio/{{.Distribution}}/istio-{{.OS}}-{{.Arch}}.tar.gz"
+	ArtifactURLTemplate string `json:"artifact_url_template,omitempty"`
+	// Variant is the image variant suffix such as "distroless" in the version running in the cluster,
+	// e.g. "1.18.2-tetrate-v0-distroless". It is never set in the manifest, and ignored in the comparisons.
+	Variant string `json:"variant,omitempty"`
+}
+
+// Artifact is the release archive of a distribution for a specific OS and architecture.
+type Artifact struct {
+	// OS and Arch in the form of GOOS and GOARCH, e.g. "linux" and "amd64"
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// URL of the archive
+	URL string `json:"url,omitempty"`
+	// SHA256 is the hex encoded sha256 checksum of the archive
+	SHA256 string `json:"sha256,omitempty"`
+	// Size of the archive in bytes
+	Size int64 `json:"size,omitempty"`
+	// IstioctlSHA256 is the hex encoded sha256 checksum of the istioctl binary in the archive,
+	// used to verify the installed binary
+	IstioctlSHA256 string `json:"istioctl_sha256,omitempty"`
 }
 
 const (
@@ -54,6 +85,23 @@ const (
 	IstioDistributionFlavorIstio       = "istio"
 )
 
+const (
+	IstioDistributionVariantDistroless = "distroless"
+	IstioDistributionVariantDebug      = "debug"
+)
+
+// IsVariant returns true if the suffix is the image variant such as "distroless".
+func IsVariant(suffix string) bool {
+	return suffix == IstioDistributionVariantDistroless || suffix == IstioDistributionVariantDebug
+}
+
+// IsManagedFlavor returns true if the flavor is distributed by the manifest, as opposed to the custom flavors of local builds.
+func IsManagedFlavor(flavor string) bool {
+	return flavor == IstioDistributionFlavorTetrate ||
+		flavor == IstioDistributionFlavorTetrateFIPS ||
+		flavor == IstioDistributionFlavorIstio
+}
+
 func (x *Manifest) GetEOLDates() (map[string]time.Time, error) {
 	ret := make(map[string]time.Time, len(x.IstioMinorVersionsEOLDates))
 	for k, v := range x.IstioMinorVersionsEOLDates {
@@ -67,39 +115,131 @@ func (x *Manifest) GetEOLDates() (map[string]time.Time, error) {
 }
 
 func (x *Manifest) SetEOLInIstioDistributions() error {
-	for _, dist := range x.IstioDistributions {
-		iVer, err := semver.NewVersion(dist.Version)
-		if err != nil {
+	for v := range x.IstioMinorVersionsEOLDates {
+		if _, err := semver.NewVersion(v); err != nil {
 			return err
 		}
-		for v, date := range x.IstioMinorVersionsEOLDates {
-			dVer, err := semver.NewVersion(v)
-			if err != nil {
-				return err
-			}
-			if (dVer.Major() == iVer.Major()) && (dVer.Minor() == iVer.Minor()) {
-				dist.EndOfLife = date
-			}
+	}
+	for _, dist := range x.IstioDistributions {
+		if _, err := semver.NewVersion(dist.Version); err != nil {
+			return err
 		}
+		dist.EndOfLife = x.GetEndOfLife(dist.Version)
 	}
 	return nil
 }
 
+// GetEndOfLife returns the end of life date of the minor version of the given Istio version, or empty if unknown.
+func (x *Manifest) GetEndOfLife(version string) string {
+	iVer, err := semver.NewVersion(version)
+	if err != nil {
+		return ""
+	}
+	for v, date := range x.IstioMinorVersionsEOLDates {
+		dVer, err := semver.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		if (dVer.Major() == iVer.Major()) && (dVer.Minor() == iVer.Minor()) {
+			return date
+		}
+	}
+	return ""
+}
+
 func parseManifestEOLDate(in string) (time.Time, error) {
 	const layout = "2006-01-02"
 	return time.Parse(layout, in)
 }
 
 func (x *IstioDistribution) String() string {
-	return fmt.Sprintf("%s-%s-v%d", x.Version, x.Flavor, x.FlavorVersion)
+	ret := fmt.Sprintf("%s-%s-v%d", x.Version, x.Flavor, x.FlavorVersion)
+	if len(x.Variant) != 0 {
+		ret += "-" + x.Variant
+	}
+	return ret
 }
 
+// Equal returns true if both are the same distribution regardless of the variants.
 func (x *IstioDistribution) Equal(j *IstioDistribution) bool {
 	return x.Version == j.Version &&
 		x.Flavor == j.Flavor &&
 		x.FlavorVersion == j.FlavorVersion
 }
 
+// GetArtifact returns the artifact for the given platform, or nil if not published.
+func (x *IstioDistribution) GetArtifact(goos, goarch string) *Artifact {
+	for _, a := range x.Artifacts {
+		if a.OS == goos && a.Arch == goarch {
+			return a
+		}
+	}
+	return nil
+}
+
+type artifactURLParams struct {
+	Distribution  string
+	Version       string
+	Flavor        string
+	FlavorVersion int64
+	OS            string
+	Arch          string
+}
+
+// ResolveArtifactURL returns the archive URL of the distribution for the given platform declared in the manifest:
+// the URL of the platform's artifact, or else the URL template of the distribution or the manifest.
+// It returns an empty string when none of them is declared.
+func (x *Manifest) ResolveArtifactURL(d *IstioDistribution, goos, goarch string) (string, error) {
+	if a := d.GetArtifact(goos, goarch); a != nil && len(a.URL) != 0 {
+		return a.URL, nil
+	}
+
+	tmpl := d.ArtifactURLTemplate
+	if len(tmpl) == 0 {
+		tmpl = x.ArtifactURLTemplate
+	}
+	if len(tmpl) == 0 {
+		return "", nil
+	}
+
+	t, err := template.New("artifact_url").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid artifact URL template %s: %v", tmpl, err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, &artifactURLParams{
+		Distribution:  d.String(),
+		Version:       d.Version,
+		Flavor:        d.Flavor,
+		FlavorVersion: d.FlavorVersion,
+		OS:            goos,
+		Arch:          goarch,
+	}); err != nil {
+		return "", fmt.Errorf("error executing artifact URL template %s: %v", tmpl, err)
+	}
+	return buf.String(), nil
+}
+
+// Verify checks the size and the sha256 checksum of the downloaded archive.
+func (a *Artifact) Verify(size int64, sum []byte) error {
+	if a.Size > 0 && a.Size != size {
+		return fmt.Errorf("size mismatch: expected %d bytes but got %d. The download may be truncated",
+			a.Size, size)
+	}
+
+	exp, err := hex.DecodeString(a.SHA256)
+	if err != nil {
+		return fmt.Errorf("invalid sha256 checksum %s in manifest: %v", a.SHA256, err)
+	}
+
+	if !bytes.Equal(exp, sum) {
+		return fmt.Errorf("sha256 checksum mismatch: expected %s but got %s",
+			a.SHA256, hex.EncodeToString(sum))
+	}
+	return nil
+}
+
 func (x *IstioDistribution) ExistInManifest(ms *Manifest) (bool, error) {
 	for _, d := range ms.IstioDistributions {
 		if d.Equal(x) {
@@ -109,26 +249,31 @@ func (x *IstioDistribution) ExistInManifest(ms *Manifest) (bool, error) {
 	return false, nil
 }
 
-func (x *IstioDistribution) Patch() (int, error) {
-	ts := strings.Split(x.Version, ".")
-	if len(ts) != 3 {
-		return 0, fmt.Errorf("invalid vesion: cannot parse %s in the form of 'x.y.z'", x.Version)
-	}
+// ParseVersion parses the upstream version of the distribution.
+func (x *IstioDistribution) ParseVersion() (*Version, error) {
+	return ParseVersion(x.Version)
+}
 
-	ret, err := strconv.Atoi(ts[2])
+func (x *IstioDistribution) Patch() (int, error) {
+	v, err := x.ParseVersion()
 	if err != nil {
-		return 0, fmt.Errorf("invalid vesion: cannot parse %s: failed to convert %s to int", x.Version, ts[2])
+		return 0, err
 	}
-	return ret, nil
+	return v.Patch(), nil
 }
 
+// Group returns the minor version and the flavor in the form of "x.y-${flavor}", e.g. "1.18-tetrate".
 func (x *IstioDistribution) Group() (string, error) {
-	ts := strings.Split(x.Version, ".")
-	if len(ts) != 3 {
-		return "", fmt.Errorf("invalid vesion: cannot parse %s in the form of 'x.y.z'", x.Version)
+	v, err := x.ParseVersion()
+	if err != nil {
+		return "", err
 	}
+	return v.MinorVersion() + "-" + x.Flavor, nil
+}
 
-	return fmt.Sprintf("%s.%s-%s", ts[0], ts[1], x.Flavor), nil
+// IsCustomFlavor returns true for the local builds registered by "getmesh link", which are not in the manifest.
+func (x *IstioDistribution) IsCustomFlavor() bool {
+	return !x.IsUpstream() && !IsManagedFlavor(x.Flavor)
 }
 
 func (x *IstioDistribution) IsUpstream() bool {
@@ -137,59 +282,77 @@ func (x *IstioDistribution) IsUpstream() bool {
 	return x.Flavor == ""
 }
 
-// compare two (patch version, flavor version) tuples in the same group
-func (x *IstioDistribution) GreaterThan(y *IstioDistribution) (bool, error) {
-	xg, err := x.Group()
+// Compare returns -1, 0 or 1 if the distribution is lower than, equal to or greater than y respectively.
+// Distributions are ordered by the upstream version, then the flavor name and then the flavor version,
+// so any two distributions are comparable across minor versions and flavors.
+func (x *IstioDistribution) Compare(y *IstioDistribution) (int, error) {
+	xv, err := x.ParseVersion()
 	if err != nil {
-		return false, err
+		return 0, err
 	}
-	yg, err := y.Group()
+	yv, err := y.ParseVersion()
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
-	if xg != yg {
-		return false, fmt.Errorf("cannot compare two versions (%s, %s) in the differnet group: %s and %s",
-			x.String(), y.String(), xg, yg)
+	if c := xv.Compare(yv); c != 0 {
+		return c, nil
 	}
-
-	xp, err := x.Patch()
-	if err != nil {
-		return false, err
+	if c := strings.Compare(x.Flavor, y.Flavor); c != 0 {
+		return c, nil
 	}
-	yp, err := y.Patch()
-	if err != nil {
-		return false, err
-	}
-
-	if xp == yp {
-		return x.FlavorVersion > y.FlavorVersion, nil
+	switch {
+	case x.FlavorVersion < y.FlavorVersion:
+		return -1, nil
+	case x.FlavorVersion > y.FlavorVersion:
+		return 1, nil
 	}
+	return 0, nil
+}
 
-	return xp > yp, nil
+// GreaterThan returns true if the distribution is ordered after y. See Compare for the ordering.
+func (x *IstioDistribution) GreaterThan(y *IstioDistribution) (bool, error) {
+	c, err := x.Compare(y)
+	return c > 0, err
 }
 
+// IstioDistributionFromString parses "x.y.z-${flavor}-v${flavor_version}" or the upstream "x.y.z",
+// where "x.y.z" may have the pre-release, e.g. "1.20.0-beta.1-tetrate-v0" or "1.20.0-beta.1",
+// and both may end with the image variant, e.g. "1.18.2-tetrate-v0-distroless" or "1.18.2-distroless".
 func IstioDistributionFromString(in string) (*IstioDistribution, error) {
-	if !strings.Contains(in, "-") {
-		// handle the upstream version schema: 'x.y.z'
-		if err := verifyUpstreamVersionString(in); err != nil {
+	parts := strings.Split(in, "-")
+
+	var variant string
+	if n := len(parts); n >= 2 && IsVariant(parts[n-1]) {
+		variant, parts = parts[n-1], parts[:n-1]
+	}
+
+	// the flavor and its version are the last two parts since the pre-release does not contain "-"
+	if n := len(parts); n >= 3 && isFlavorVersion(parts[n-1]) {
+		version := strings.Join(parts[:n-2], "-")
+		if _, err := ParseVersion(version); err != nil {
 			return nil, err
 		}
 
-		return &IstioDistribution{Version: in}, nil
+		flavor, flavorVersion, err := parseFlavor(parts[n-2] + "-" + parts[n-1])
+		return &IstioDistribution{Version: version, Flavor: flavor, FlavorVersion: flavorVersion, Variant: variant}, err
 	}
 
-	parts := strings.SplitN(in, "-", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid version schema: %s", in)
+	// handle the upstream version schema: 'x.y.z', where the misplaced flavor or variant is rejected
+	// as the unknown pre-release
+	version := strings.Join(parts, "-")
+	if _, err := ParseVersion(version); err != nil {
+		return nil, fmt.Errorf("invalid version schema: %s: %w", in, err)
 	}
+	return &IstioDistribution{Version: version, Variant: variant}, nil
+}
 
-	if err := verifyUpstreamVersionString(parts[0]); err != nil {
-		return nil, err
+func isFlavorVersion(in string) bool {
+	if !strings.HasPrefix(in, "v") {
+		return false
 	}
-
-	flavor, flavorVersion, err := parseFlavor(parts[1])
-	return &IstioDistribution{Version: parts[0], Flavor: flavor, FlavorVersion: flavorVersion}, err
+	_, err := strconv.ParseInt(in[1:], 10, 64)
+	return err == nil
 }
 
 func parseFlavor(in string) (string, int64, error) {
@@ -206,34 +369,29 @@ func parseFlavor(in string) (string, int64, error) {
 	return flavor, flavorVersion, nil
 }
 
-func verifyUpstreamVersionString(in string) error {
-	ts := strings.Split(in, ".")
-	if len(ts) != 3 {
-		return fmt.Errorf("invalid vesion: cannot parse %s in the form of 'x.y.z'", in)
-	}
-
-	for _, t := range ts {
-		if _, err := strconv.Atoi(t); err != nil {
-			return fmt.Errorf("invalid vesion: cannot parse %s in the form of 'x.y.z'", in)
-		}
-	}
-	return nil
-}
-
-// get the istio distribution with latest patch version and latest flavor version
+// get the istio distribution with latest patch version and latest flavor version.
+// Pre-releases are only selected when the current one is a pre-release.
 func GetLatestDistribution(current *IstioDistribution, ms *Manifest) (foundLatest *IstioDistribution, includeSecurityPatch bool, err error) {
 	tg, err := current.Group()
 	if err != nil {
 		return nil, false, err
 	}
+	cv, err := current.ParseVersion()
+	if err != nil {
+		return nil, false, err
+	}
 
 	for _, d := range ms.IstioDistributions {
 		dg, err := d.Group()
 		if err != nil {
 			return nil, false, err
 		}
+		dv, err := d.ParseVersion()
+		if err != nil {
+			return nil, false, err
+		}
 
-		if tg == dg {
+		if tg == dg && (!dv.IsPreRelease() || cv.IsPreRelease()) {
 			// if there are any version between current and latest version has security patch
 			// includeSecurityPatch should return true
 			if ok, _ := d.GreaterThan(current); ok && d.IsSecurityPatch {
@@ -249,3 +407,30 @@ func GetLatestDistribution(current *IstioDistribution, ms *Manifest) (foundLates
 	}
 	return
 }
+
+// GetLatestDistributionInFlavor returns the greatest release of the flavor across all the minor versions,
+// or nil if the manifest has none. Pre-releases are never selected.
+func GetLatestDistributionInFlavor(flavor string, ms *Manifest) (*IstioDistribution, error) {
+	var ret *IstioDistribution
+	for _, d := range ms.IstioDistributions {
+		if d.Flavor != flavor {
+			continue
+		}
+
+		v, err := d.ParseVersion()
+		if err != nil {
+			return nil, err
+		} else if v.IsPreRelease() {
+			continue
+		}
+
+		if ret == nil {
+			ret = d
+		} else if ok, err := d.GreaterThan(ret); err != nil {
+			return nil, err
+		} else if ok {
+			ret = d
+		}
+	}
+	return ret, nil
+}
@@ -15,6 +15,8 @@
 package manifest
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"testing"
 
@@ -126,6 +128,81 @@ func TestIstioDistributionEqual(t *testing.T) {
 	}
 }
 
+func TestIstioDistribution_GetArtifact(t *testing.T) {
+	d := &IstioDistribution{
+		Version: "1.7.3", Flavor: IstioDistributionFlavorTetrate,
+		Artifacts: []*Artifact{
+			{OS: "linux", Arch: "amd64", SHA256: "aa"},
+			{OS: "darwin", Arch: "arm64", SHA256: "bb"},
+		},
+	}
+	require.Equal(t, "aa", d.GetArtifact("linux", "amd64").SHA256)
+	require.Equal(t, "bb", d.GetArtifact("darwin", "arm64").SHA256)
+	require.Nil(t, d.GetArtifact("linux", "arm64"))
+}
+
+func TestManifest_ResolveArtifactURL(t *testing.T) {
+	d := &IstioDistribution{
+		Version: "1.7.3", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 1,
+		Artifacts: []*Artifact{
+			{OS: "linux", Arch: "amd64", URL: "https://example.com/linux-amd64.tar.gz"},
+			{OS: "linux", Arch: "arm64", SHA256: "aa"},
+		},
+	}
+
+	for _, c := range []struct {
+		name                     string
+		manifestTmpl, distroTmpl string
+		goos, goarch             string
+		exp                      string
+	}{
+		{name: "artifact", goos: "linux", goarch: "amd64", exp: "https://example.com/linux-amd64.tar.gz"},
+		{name: "artifact precedes template", distroTmpl: "https://example.com/{{.OS}}.tar.gz",
+			goos: "linux", goarch: "amd64", exp: "https://example.com/linux-amd64.tar.gz"},
+		{name: "none", goos: "linux", goarch: "arm64", exp: ""},
+		{name: "distribution template", distroTmpl: "https://example.com/{{.Distribution}}/istio-{{.OS}}-{{.Arch}}.tar.gz",
+			manifestTmpl: "https://ignored.example.com", goos: "linux", goarch: "arm64",
+			exp: "https://example.com/1.7.3-tetrate-v1/istio-linux-arm64.tar.gz"},
+		{name: "manifest template", manifestTmpl: "https://example.com/{{.Version}}/{{.Flavor}}-v{{.FlavorVersion}}/" +
+			`{{if eq .OS "darwin"}}osx{{else}}{{.OS}}{{end}}.tar.gz`,
+			goos: "darwin", goarch: "amd64", exp: "https://example.com/1.7.3/tetrate-v1/osx.tar.gz"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			d.ArtifactURLTemplate = c.distroTmpl
+			ms := &Manifest{IstioDistributions: []*IstioDistribution{d}, ArtifactURLTemplate: c.manifestTmpl}
+			actual, err := ms.ResolveArtifactURL(d, c.goos, c.goarch)
+			require.NoError(t, err)
+			require.Equal(t, c.exp, actual)
+		})
+	}
+
+	t.Run("invalid template", func(t *testing.T) {
+		d.ArtifactURLTemplate = "https://example.com/{{.Unknown}}"
+		_, err := (&Manifest{}).ResolveArtifactURL(d, "linux", "arm64")
+		require.Error(t, err)
+
+		d.ArtifactURLTemplate = "https://example.com/{{.OS"
+		_, err = (&Manifest{}).ResolveArtifactURL(d, "linux", "arm64")
+		require.Error(t, err)
+	})
+}
+
+func TestArtifact_Verify(t *testing.T) {
+	body := []byte("istio archive")
+	sum := sha256.Sum256(body)
+
+	a := &Artifact{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(body))}
+	require.NoError(t, a.Verify(int64(len(body)), sum[:]))
+
+	// size is optional
+	require.NoError(t, (&Artifact{SHA256: a.SHA256}).Verify(1, sum[:]))
+
+	other := sha256.Sum256([]byte("tampered"))
+	require.Error(t, a.Verify(int64(len(body)), other[:]))
+	require.Error(t, a.Verify(int64(len(body))-1, sum[:]))
+	require.Error(t, (&Artifact{SHA256: "not hex"}).Verify(1, sum[:]))
+}
+
 func TestIstioDistribution_ExistInManifest(t *testing.T) {
 	ms := &Manifest{
 		IstioDistributions: []*IstioDistribution{
@@ -168,6 +245,7 @@ func TestIstioDistribution_Group(t *testing.T) {
 		{exp: "1.3-tetrate", in: &IstioDistribution{Version: "1.3.1", Flavor: "tetrate"}},
 		{exp: "1.7-tetratefips", in: &IstioDistribution{Version: "1.7.6", Flavor: "tetratefips"}},
 		{exp: "1.8-istio", in: &IstioDistribution{Version: "1.8.3", Flavor: "istio"}},
+		{exp: "1.20-tetrate", in: &IstioDistribution{Version: "1.20.0-beta.1", Flavor: "tetrate"}},
 	} {
 		actual, err := c.in.Group()
 		require.NoError(t, err)
@@ -181,6 +259,13 @@ func TestIstioDistribution_IsUpstream(t *testing.T) {
 	require.False(t, (&IstioDistribution{Flavor: "tetratefips"}).IsUpstream())
 }
 
+func TestIstioDistribution_IsCustomFlavor(t *testing.T) {
+	require.True(t, (&IstioDistribution{Flavor: "acme"}).IsCustomFlavor())
+	require.False(t, (&IstioDistribution{Flavor: ""}).IsCustomFlavor())
+	require.False(t, (&IstioDistribution{Flavor: "tetrate"}).IsCustomFlavor())
+	require.False(t, (&IstioDistribution{Flavor: "istio"}).IsCustomFlavor())
+}
+
 func TestIstioDistribution_GreaterThan(t *testing.T) {
 	base := &IstioDistribution{Version: "1.7.30", FlavorVersion: 40}
 	t.Run("true", func(t *testing.T) {
@@ -198,18 +283,101 @@ func TestIstioDistribution_GreaterThan(t *testing.T) {
 		for _, c := range []*IstioDistribution{
 			{Version: base.Version, FlavorVersion: base.FlavorVersion + 1},
 			{Version: "1.7.50", FlavorVersion: base.FlavorVersion},
+			{Version: "1.8.0", FlavorVersion: 0},
+			{Version: "1.8.0-alpha.0", FlavorVersion: 0},
+			base,
 		} {
 			actual, err := base.GreaterThan(c)
 			require.NoError(t, err)
 			require.False(t, actual)
 		}
 	})
+
+	t.Run("across minor versions", func(t *testing.T) {
+		for _, c := range []*IstioDistribution{
+			{Version: "1.6.100", FlavorVersion: 100},
+			{Version: "1.7.30-rc.1", FlavorVersion: 100},
+			{Version: "0.8.0", Flavor: "tetratefips"},
+		} {
+			actual, err := base.GreaterThan(c)
+			require.NoError(t, err)
+			require.True(t, actual)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := base.GreaterThan(&IstioDistribution{Version: "1.7"})
+		require.Error(t, err)
+	})
+}
+
+func TestIstioDistribution_Compare(t *testing.T) {
+	// in the ascending order
+	ordered := []*IstioDistribution{
+		{Version: "1.17.8", Flavor: "tetratefips", FlavorVersion: 3},
+		{Version: "1.18.0-rc.1", Flavor: "tetrate", FlavorVersion: 0},
+		{Version: "1.18.0", Flavor: "istio", FlavorVersion: 0},
+		{Version: "1.18.0", Flavor: "tetrate", FlavorVersion: 0},
+		{Version: "1.18.0", Flavor: "tetrate", FlavorVersion: 1},
+		{Version: "1.18.0", Flavor: "tetratefips", FlavorVersion: 0},
+		{Version: "1.18.2", Flavor: "istio", FlavorVersion: 0},
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			var exp int
+			if i < j {
+				exp = -1
+			} else if i > j {
+				exp = 1
+			}
+			actual, err := ordered[i].Compare(ordered[j])
+			require.NoError(t, err)
+			require.Equal(t, exp, actual, "%s vs %s", ordered[i].String(), ordered[j].String())
+		}
+	}
+}
+
+func TestGetLatestDistributionInFlavor(t *testing.T) {
+	ms := &Manifest{
+		IstioDistributions: []*IstioDistribution{
+			{Version: "1.9.3", Flavor: "tetrate", FlavorVersion: 0},
+			{Version: "1.20.0-beta.1", Flavor: "tetrate", FlavorVersion: 0},
+			{Version: "1.10.1", Flavor: "tetrate", FlavorVersion: 0},
+			{Version: "1.10.1", Flavor: "tetrate", FlavorVersion: 2},
+			{Version: "1.10.1", Flavor: "tetrate", FlavorVersion: 1},
+			{Version: "1.11.0", Flavor: "tetratefips", FlavorVersion: 0},
+		},
+	}
+
+	actual, err := GetLatestDistributionInFlavor("tetrate", ms)
+	require.NoError(t, err)
+	require.Equal(t, "1.10.1-tetrate-v2", actual.String())
+
+	actual, err = GetLatestDistributionInFlavor("tetratefips", ms)
+	require.NoError(t, err)
+	require.Equal(t, "1.11.0-tetratefips-v0", actual.String())
+
+	actual, err = GetLatestDistributionInFlavor("istio", ms)
+	require.NoError(t, err)
+	require.Nil(t, actual)
+
+	ms.IstioDistributions = append(ms.IstioDistributions, &IstioDistribution{Version: "1.x", Flavor: "tetrate"})
+	_, err = GetLatestDistributionInFlavor("tetrate", ms)
+	require.Error(t, err)
+}
+
+func TestIstioDistribution_String(t *testing.T) {
+	require.Equal(t, "1.18.2-tetrate-v0", (&IstioDistribution{Version: "1.18.2", Flavor: "tetrate"}).String())
+	require.Equal(t, "1.18.2-tetrate-v0-distroless",
+		(&IstioDistribution{Version: "1.18.2", Flavor: "tetrate", Variant: "distroless"}).String())
 }
 
 func TestIstioDistribution_Equal(t *testing.T) {
 	base := &IstioDistribution{Version: "1.2.3", Flavor: "tetrate", FlavorVersion: 40}
 	t.Run("true", func(t *testing.T) {
 		require.True(t, base.Equal(&IstioDistribution{Version: "1.2.3", Flavor: "tetrate", FlavorVersion: 40}))
+		require.True(t, base.Equal(&IstioDistribution{Version: "1.2.3", Flavor: "tetrate", FlavorVersion: 40, Variant: "distroless"}))
 	})
 
 	t.Run("false", func(t *testing.T) {
@@ -244,6 +412,18 @@ func TestIstioDistributionFromString(t *testing.T) {
 				exp: &IstioDistribution{Version: "2001.7.3", Flavor: "tetrate", FlavorVersion: 0}},
 			{in: "2001.7.300-tetratefips-v10",
 				exp: &IstioDistribution{Version: "2001.7.300", Flavor: "tetratefips", FlavorVersion: 10}},
+			{in: "1.20.0-beta.1-tetrate-v0",
+				exp: &IstioDistribution{Version: "1.20.0-beta.1", Flavor: "tetrate", FlavorVersion: 0}},
+			{in: "1.20.0-rc.0-istio-v0",
+				exp: &IstioDistribution{Version: "1.20.0-rc.0", Flavor: "istio", FlavorVersion: 0}},
+			{in: "1.8.3", exp: &IstioDistribution{Version: "1.8.3"}},
+			{in: "1.20.0-beta.1", exp: &IstioDistribution{Version: "1.20.0-beta.1"}},
+			{in: "1.18.2-tetrate-v0-distroless",
+				exp: &IstioDistribution{Version: "1.18.2", Flavor: "tetrate", FlavorVersion: 0, Variant: "distroless"}},
+			{in: "1.20.0-rc.0-tetratefips-v1-debug",
+				exp: &IstioDistribution{Version: "1.20.0-rc.0", Flavor: "tetratefips", FlavorVersion: 1, Variant: "debug"}},
+			{in: "1.18.2-distroless", exp: &IstioDistribution{Version: "1.18.2", Variant: "distroless"}},
+			{in: "1.20.0-beta.1-debug", exp: &IstioDistribution{Version: "1.20.0-beta.1", Variant: "debug"}},
 		} {
 			v, err := IstioDistributionFromString(c.in)
 			require.NoError(t, err, c.in, c.in)
@@ -256,6 +436,10 @@ func TestIstioDistributionFromString(t *testing.T) {
 			"1.6", "1.7.113r",
 			"1.6.7-", "1.7.113r-tetrate-v1", "1.7.113-tetrate",
 			"1.6.7-tetrate-v", "1.7.113-tetrate-",
+			"1.20.0-beta.1-tetrate", "1.20-beta.1-tetrate-v0", "1.20.0-beta-1-tetrate-v0",
+			"distroless", "1.18.2-tetrate-distroless", "1.18.2-distroless-tetrate-v0", "1.18.2-tetrate-v0-distroless-debug",
+			// the mistyped flavor is not a pre-release
+			"1.18.2-acme", "1.18.2-acme-debug", "1.20.0-beta-tetrate-v0",
 		} {
 			_, err := IstioDistributionFromString(in)
 			require.Error(t, err, in)
@@ -566,6 +750,30 @@ func TestGetLatestDistribution(t *testing.T) {
 			wants:       nil,
 			wantsSecure: false,
 		},
+		{
+			name: "pre-release skipped",
+			maniest: &Manifest{
+				IstioDistributions: []*IstioDistribution{
+					{Version: "1.20.1", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+					{Version: "1.20.2-rc.0", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0, IsSecurityPatch: true},
+				},
+			},
+			current:     &IstioDistribution{Version: "1.20.0", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+			wants:       &IstioDistribution{Version: "1.20.1", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+			wantsSecure: false,
+		},
+		{
+			name: "pre-release current",
+			maniest: &Manifest{
+				IstioDistributions: []*IstioDistribution{
+					{Version: "1.20.0-beta.1", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+					{Version: "1.20.0-rc.0", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+				},
+			},
+			current:     &IstioDistribution{Version: "1.20.0-beta.1", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+			wants:       &IstioDistribution{Version: "1.20.0-rc.0", Flavor: IstioDistributionFlavorTetrate, FlavorVersion: 0},
+			wantsSecure: false,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -0,0 +1,84 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Version is the upstream Istio version in the form of "x.y.z" with the optional pre-release, e.g. "1.20.0-beta.1".
+// Versions are ordered by the semantic versioning rules, so the pre-releases precede the release of the same x.y.z.
+type Version struct {
+	v *semver.Version
+}
+
+// preReleasePattern matches the pre-releases published by Istio, e.g. "alpha.0", "beta.1" and "rc.2".
+var preReleasePattern = regexp.MustCompile(`^(alpha|beta|rc)\.(0|[1-9][0-9]*)$`)
+
+// ParseVersion parses "x.y.z" or "x.y.z-<pre-release>" where the pre-release is one of "alpha.N", "beta.N"
+// and "rc.N". Unlike semver.NewVersion, the "v" prefix, the shorthand "x.y", the build metadata and any other
+// pre-release are rejected, so that a mistyped flavor such as "1.18.2-acme" is never taken as a pre-release.
+func ParseVersion(in string) (*Version, error) {
+	v, err := semver.NewVersion(in)
+	if err != nil || v.String() != in || len(v.Metadata()) != 0 ||
+		(len(v.Prerelease()) != 0 && !preReleasePattern.MatchString(v.Prerelease())) {
+		return nil, fmt.Errorf("invalid version: cannot parse %s in the form of 'x.y.z' or 'x.y.z-<alpha|beta|rc>.N'", in)
+	}
+	return &Version{v: v}, nil
+}
+
+func (v *Version) String() string {
+	return v.v.String()
+}
+
+func (v *Version) Major() int {
+	return int(v.v.Major())
+}
+
+func (v *Version) Minor() int {
+	return int(v.v.Minor())
+}
+
+func (v *Version) Patch() int {
+	return int(v.v.Patch())
+}
+
+// MinorVersion returns "x.y" of the version.
+func (v *Version) MinorVersion() string {
+	return fmt.Sprintf("%d.%d", v.v.Major(), v.v.Minor())
+}
+
+// SameMinor returns true if both versions are in the same x.y.
+func (v *Version) SameMinor(o *Version) bool {
+	return v.v.Major() == o.v.Major() && v.v.Minor() == o.v.Minor()
+}
+
+// PreRelease returns the dot separated identifiers after "-", e.g. "beta.1". Empty for the releases.
+func (v *Version) PreRelease() string {
+	return v.v.Prerelease()
+}
+
+// IsPreRelease returns true for the alpha, beta and rc versions.
+func (v *Version) IsPreRelease() bool {
+	return len(v.PreRelease()) != 0
+}
+
+// Compare returns -1, 0 or 1 if the version is lower than, equal to or greater than o respectively.
+func (v *Version) Compare(o *Version) int {
+	return v.v.Compare(o.v)
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		for _, c := range []struct {
+			in                  string
+			major, minor, patch int
+			preRelease          string
+		}{
+			{in: "1.7.3", major: 1, minor: 7, patch: 3},
+			{in: "2001.17.300", major: 2001, minor: 17, patch: 300},
+			{in: "1.20.0-beta.1", major: 1, minor: 20, patch: 0, preRelease: "beta.1"},
+			{in: "1.20.0-rc.0", major: 1, minor: 20, patch: 0, preRelease: "rc.0"},
+			{in: "1.20.0-alpha.10", major: 1, minor: 20, patch: 0, preRelease: "alpha.10"},
+		} {
+			actual, err := ParseVersion(c.in)
+			require.NoError(t, err, c.in)
+			require.Equal(t, c.major, actual.Major())
+			require.Equal(t, c.minor, actual.Minor())
+			require.Equal(t, c.patch, actual.Patch())
+			require.Equal(t, c.preRelease, actual.PreRelease())
+			require.Equal(t, c.preRelease != "", actual.IsPreRelease())
+			require.Equal(t, c.in, actual.String())
+		}
+	})
+
+	t.Run("ng", func(t *testing.T) {
+		for _, in := range []string{
+			"", "1.6", "1.7.113r", "v1.7.3", "1.7.3.1", "1.-7.3", "1.+7.3",
+			"1.6.7-", "1.20.0-beta..1", "1.20.0-beta.1-tetrate-v0", "1.20.0-beta+1", "01.7.3",
+			// only the pre-releases published by Istio are accepted
+			"1.18.2-acme", "1.18.2-tetrate", "1.20.0-alpha", "1.20.0-beta.01", "1.20.0-alpha.beta", "1.20.0-rc.1.1",
+		} {
+			_, err := ParseVersion(in)
+			require.Error(t, err, in)
+		}
+	})
+}
+
+func TestVersion_Compare(t *testing.T) {
+	// in the ascending order
+	ordered := []string{
+		"1.9.9",
+		"1.10.0-alpha.0",
+		"1.10.0-alpha.1",
+		"1.10.0-beta.0",
+		"1.10.0-beta.2",
+		"1.10.0-beta.11",
+		"1.10.0-rc.0",
+		"1.10.0-rc.1",
+		"1.10.0",
+		"1.10.1",
+		"2.0.0",
+	}
+
+	vs := make([]*Version, len(ordered))
+	for i, in := range ordered {
+		v, err := ParseVersion(in)
+		require.NoError(t, err)
+		vs[i] = v
+	}
+
+	for i := range vs {
+		for j := range vs {
+			var exp int
+			if i < j {
+				exp = -1
+			} else if i > j {
+				exp = 1
+			}
+			require.Equal(t, exp, vs[i].Compare(vs[j]), "%s vs %s", vs[i], vs[j])
+		}
+	}
+
+	shuffled := []*Version{vs[10], vs[3], vs[0], vs[8], vs[5], vs[1], vs[9], vs[6], vs[2], vs[7], vs[4]}
+	sort.Slice(shuffled, func(i, j int) bool { return shuffled[i].Compare(shuffled[j]) < 0 })
+	require.Equal(t, vs, shuffled)
+}
+
+func TestVersion_SameMinor(t *testing.T) {
+	parse := func(in string) *Version {
+		v, err := ParseVersion(in)
+		require.NoError(t, err)
+		return v
+	}
+
+	base := parse("1.18.2")
+	require.True(t, base.SameMinor(parse("1.18.0-rc.0")))
+	require.False(t, base.SameMinor(parse("1.17.2")))
+	require.False(t, base.SameMinor(parse("2.18.2")))
+	require.Equal(t, "1.18", base.MinorVersion())
+}
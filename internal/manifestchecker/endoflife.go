@@ -18,8 +18,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/Masterminds/semver"
-
 	"github.com/tetratelabs/getmesh/internal/getmesh"
 	"github.com/tetratelabs/getmesh/internal/manifest"
 	"github.com/tetratelabs/getmesh/internal/util/logger"
@@ -31,11 +29,11 @@ func endOfLifeChecker(m *manifest.Manifest) error {
 
 func endOfLifeCheckerImpl(m *manifest.Manifest, now time.Time) error {
 	current := getmesh.GetActiveConfig().IstioDistribution
-	if current == nil {
+	if current == nil || current.IsCustomFlavor() {
 		return nil
 	}
 
-	currentVer, err := semver.NewVersion(current.Version)
+	currentVer, err := current.ParseVersion()
 	if err != nil {
 		return err
 	}
@@ -47,24 +45,24 @@ func endOfLifeCheckerImpl(m *manifest.Manifest, now time.Time) error {
 
 	var greaterVersions []string
 	for _, d := range m.IstioDistributions {
-		v, err := semver.NewVersion(d.Version)
+		v, err := d.ParseVersion()
 		if err != nil {
 			return err
 		}
 
-		if v.Minor() > currentVer.Minor() {
+		if !v.SameMinor(currentVer) && v.Compare(currentVer) > 0 {
 			greaterVersions = append(greaterVersions, d.String())
 		}
 
 	}
 
 	for mv, eol := range dates {
-		v, err := semver.NewVersion(mv)
+		v, err := manifest.ParseVersion(mv + ".0")
 		if err != nil {
 			return err
 		}
 
-		if v.Minor() == currentVer.Minor() && eol.UTC().AddDate(0, -1, 0).Before(now) {
+		if v.SameMinor(currentVer) && eol.UTC().AddDate(0, -1, 0).Before(now) {
 			logger.Warnf("Your current active minor version %s is reaching the end of life on %s. "+
 				"We strongly recommend you to upgrade to the available higher minor versions: %s.\n",
 				mv, eol.Format("2006-01-02"), strings.Join(greaterVersions, ", "))
@@ -74,6 +74,26 @@ func Test_endOfLifeChecker(t *testing.T) {
 		require.Equal(t, "", buf.String())
 	})
 
+	t.Run("pre-release", func(t *testing.T) {
+		require.NoError(t, getmesh.SetIstioVersion(home, &manifest.IstioDistribution{Version: "1.9.0-rc.0"}))
+		buf := logger.ExecuteWithLock(func() {
+			now := time.Date(2020, 11, 5, 0, 0, 0, 0, time.Local)
+			require.NoError(t, endOfLifeCheckerImpl(m, now))
+		})
+
+		require.Equal(t, "", buf.String())
+	})
+
+	t.Run("custom flavor", func(t *testing.T) {
+		require.NoError(t, getmesh.SetIstioVersion(home, &manifest.IstioDistribution{Version: "1.7.1", Flavor: "acme", FlavorVersion: 3}))
+		buf := logger.ExecuteWithLock(func() {
+			now := time.Date(2020, 11, 5, 0, 0, 0, 0, time.Local)
+			require.NoError(t, endOfLifeCheckerImpl(m, now))
+		})
+
+		require.Equal(t, "", buf.String())
+	})
+
 	t.Run("warn", func(t *testing.T) {
 		now := time.Date(2020, 11, 5, 0, 0, 0, 0, time.Local)
 		exp := `[WARNING] Your current active minor version %s is reaching the end of life on 2020-10-10. We strongly recommend you to upgrade to the available higher minor versions: 1.8.1-tetrate-v0, 1.9.10-tetratefips-v0, 1.9.0-istio-v0.`
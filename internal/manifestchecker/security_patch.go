@@ -69,6 +69,11 @@ func securityPatchCheckerImpl(homedir string, m *manifest.Manifest) error {
 func constructLatestVersionsMap(in []*manifest.IstioDistribution) (map[string]*manifest.IstioDistribution, error) {
 	ret := map[string]*manifest.IstioDistribution{}
 	for _, v := range in {
+		if v.IsCustomFlavor() {
+			// the linked builds are not supported by the manifest
+			continue
+		}
+
 		vg, err := v.Group()
 		if err != nil {
 			return nil, err
@@ -41,6 +41,8 @@ func Test_securityPatchCheckerImpl(t *testing.T) {
 		{Version: "1.9.1", Flavor: manifest.IstioDistributionFlavorTetrate, FlavorVersion: 0},
 		// up-to-date
 		{Version: "1.10.1", Flavor: manifest.IstioDistributionFlavorTetrate, FlavorVersion: 0},
+		// linked build of the custom flavor
+		{Version: "1.2.1", Flavor: "acme", FlavorVersion: 3},
 	}
 
 	remotes := []*manifest.IstioDistribution{
@@ -76,7 +78,7 @@ func Test_securityPatchCheckerImpl(t *testing.T) {
 		require.Contains(t, msg, exp)
 	}
 
-	for _, nexp := range []string{"1.10", "1.8.2"} {
+	for _, nexp := range []string{"1.10", "1.8.2", "acme"} {
 		require.NotContains(t, msg, nexp)
 	}
 
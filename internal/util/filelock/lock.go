@@ -0,0 +1,106 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filelock provides the advisory file locks shared among getmesh processes.
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Lock acquires the exclusive lock of the file at path, creating it if necessary,
+// and blocks until the other process releases it. The returned func releases the lock.
+func Lock(path string) (func(), error) {
+	return lock(path, false)
+}
+
+// RLock acquires the shared lock of the file at path, which is held along with the other shared locks
+// but blocks until the exclusive one is released. The returned func releases the lock.
+func RLock(path string) (func(), error) {
+	return lock(path, true)
+}
+
+// LockRemovable acquires the exclusive lock like Lock, and the returned func removes the lock file before
+// releasing the lock, so that no lock file is left for the transient resource such as a download.
+func LockRemovable(path string) (func(), error) {
+	for {
+		f, err := lockOpen(path, false)
+		if err != nil {
+			return nil, err
+		}
+
+		// the file locked after waiting may have been removed by the previous holder,
+		// in which case the next process would lock the new one at path
+		if same, err := isFileAt(f, path); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error locking %s: %v", path, err)
+		} else if !same {
+			f.Close()
+			continue
+		}
+
+		return func() {
+			// the removal may fail on the platforms not allowing it for the open file, which only leaves the file
+			_ = os.Remove(path)
+			_ = f.Close()
+		}, nil
+	}
+}
+
+func lock(path string, shared bool) (func(), error) {
+	f, err := lockOpen(path, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		// closing the file releases the lock, and the second close is no-op
+		_ = f.Close()
+	}, nil
+}
+
+func lockOpen(path string, shared bool) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file %s: %v", path, err)
+	}
+
+	if err := lockFile(f, shared); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error locking %s: %v", path, err)
+	}
+	return f, nil
+}
+
+func isFileAt(f *os.File, path string) (bool, error) {
+	opened, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	current, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return os.SameFile(opened, current), nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", ".lock")
+	unlock, err := Lock(path)
+	require.NoError(t, err)
+
+	// require must not be called outside the test goroutine, so the result is sent back
+	acquired := make(chan error, 1)
+	go func() {
+		// flock conflicts among the open files even in the same process
+		unlock, err := Lock(path)
+		if err == nil {
+			unlock()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the lock must not be acquired while another one holds it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case err := <-acquired:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock must be acquired after released")
+	}
+}
+
+func TestRLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".lock")
+	runlock, err := RLock(path)
+	require.NoError(t, err)
+
+	// the shared locks are held together
+	other, err := RLock(path)
+	require.NoError(t, err)
+	other()
+
+	acquired := make(chan error, 1)
+	go func() {
+		unlock, err := Lock(path)
+		if err == nil {
+			unlock()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the exclusive lock must not be acquired while the shared one is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	runlock()
+	select {
+	case err := <-acquired:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the exclusive lock must be acquired after the shared one is released")
+	}
+}
+
+func TestLockRemovable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "download.lock")
+	unlock, err := LockRemovable(path)
+	require.NoError(t, err)
+
+	acquired := make(chan error, 1)
+	go func() {
+		// waits on the file removed by the holder, and locks the new one at path
+		unlock, err := LockRemovable(path)
+		if err == nil {
+			_, err = os.Stat(path)
+			unlock()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the lock must not be acquired while another one holds it")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case err := <-acquired:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock must be acquired after released")
+	}
+
+	// no lock file is left
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filelock
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/tetratelabs/getmesh/internal/util/logger"
+)
+
+// lockFile blocks until the LockFileEx of the whole f is acquired, shared or exclusive depending on shared
+func lockFile(f *os.File, shared bool) error {
+	var flags uint32
+	if !shared {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+
+	h := windows.Handle(f.Fd())
+	// lock the whole file regardless of its size
+	err := windows.LockFileEx(h, flags|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, ^uint32(0), ^uint32(0), new(windows.Overlapped))
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		logger.Infof("waiting for another getmesh process to release the lock on %s\n", f.Name())
+		err = windows.LockFileEx(h, flags, 0, ^uint32(0), ^uint32(0), new(windows.Overlapped))
+	}
+	return err
+}
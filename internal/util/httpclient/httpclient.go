@@ -0,0 +1,158 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpclient provides the HTTP client shared by the manifest and istioctl downloads.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"time"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultRetries = 3
+)
+
+// Config of the shared HTTP client.
+type Config struct {
+	// Proxy is the URL of the proxy used for all requests. Defaults to HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+	Proxy string
+	// CABundle is the path to the PEM encoded CA certificates trusted in addition to the system ones.
+	CABundle string
+	// Timeout for connecting and waiting for the response headers. The body, such as a large archive, is not limited.
+	Timeout time.Duration
+	// Retries is the number of retries on connection errors and 5xx responses.
+	Retries int
+	// Version of getmesh sent in User-Agent.
+	Version string
+}
+
+// the client without retries until configured
+var (
+	client    = newClient(http.ProxyFromEnvironment, nil, DefaultTimeout)
+	retries   = 0
+	userAgent = newUserAgent("dev")
+
+	// the wait before the n-th retry, starting from 1
+	backoff = func(n int) time.Duration {
+		return time.Duration(1<<(n-1)) * time.Second
+	}
+)
+
+// Configure replaces the shared HTTP client.
+func Configure(c Config) error {
+	proxy := http.ProxyFromEnvironment
+	if len(c.Proxy) != 0 {
+		u, err := url.Parse(c.Proxy)
+		if err != nil || len(u.Host) == 0 {
+			return fmt.Errorf("invalid proxy %s: must be a URL such as http://proxy.example.com:3128", c.Proxy)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	var pool *x509.CertPool
+	if len(c.CABundle) != 0 {
+		raw, err := os.ReadFile(c.CABundle)
+		if err != nil {
+			return fmt.Errorf("error reading CA bundle: %v", err)
+		}
+		if pool, err = x509.SystemCertPool(); err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(raw) {
+			return fmt.Errorf("invalid CA bundle %s: no PEM encoded certificate found", c.CABundle)
+		}
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("invalid retries %d: must not be negative", c.Retries)
+	}
+
+	client = newClient(proxy, pool, c.Timeout)
+	retries = c.Retries
+	userAgent = newUserAgent(c.Version)
+	return nil
+}
+
+func newClient(proxy func(*http.Request) (*url.URL, error), pool *x509.CertPool, timeout time.Duration) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxy
+	t.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = timeout
+	t.ResponseHeaderTimeout = timeout
+	if pool != nil {
+		t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &http.Client{Transport: t}
+}
+
+func newUserAgent(version string) string {
+	return fmt.Sprintf("getmesh/%s (%s/%s)", version, runtime.GOOS, runtime.GOARCH)
+}
+
+// Do sends the request with User-Agent set, retrying with backoff on connection errors and 5xx responses.
+// The request must not have a body. The caller closes the body of the returned response.
+func Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", userAgent)
+	for n := 1; ; n++ {
+		res, err := client.Do(req)
+		if n > retries || !shouldRetry(req, res, err) {
+			return res, err
+		}
+		if res != nil {
+			// drain to reuse the connection
+			_, _ = io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(backoff(n)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func shouldRetry(req *http.Request, res *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !isCertificateError(err)
+	}
+	return res.StatusCode >= 500
+}
+
+// the untrusted certificate never becomes trusted by retrying
+func isCertificateError(err error) bool {
+	var (
+		uaerr x509.UnknownAuthorityError
+		herr  x509.HostnameError
+		cerr  x509.CertificateInvalidError
+	)
+	return errors.As(err, &uaerr) || errors.As(err, &herr) || errors.As(err, &cerr)
+}
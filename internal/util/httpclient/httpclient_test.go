@@ -0,0 +1,160 @@
+// Copyright 2021 Tetrate
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRequest(t *testing.T, url string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	return req
+}
+
+func TestDo(t *testing.T) {
+	defer func(b func(int) time.Duration) { backoff = b }(backoff)
+	var waits []time.Duration
+	backoff = func(n int) time.Duration {
+		waits = append(waits, time.Duration(1<<(n-1))*time.Second)
+		return time.Millisecond
+	}
+
+	require.NoError(t, Configure(Config{Retries: 3, Version: "1.1.5"}))
+	defer func() { require.NoError(t, Configure(Config{})) }()
+
+	var count int
+	var agents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		agents = append(agents, r.UserAgent())
+		switch r.URL.Path {
+		case "/flaky":
+			if count < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case "/not-found":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	t.Run("retry on 5xx", func(t *testing.T) {
+		count, waits, agents = 0, nil, nil
+		res, err := Do(newRequest(t, ts.URL+"/flaky"))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "ok", string(body))
+		require.Equal(t, 3, count)
+		require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+		for _, a := range agents {
+			require.Equal(t, "getmesh/1.1.5 ("+runtime.GOOS+"/"+runtime.GOARCH+")", a)
+		}
+	})
+
+	t.Run("give up", func(t *testing.T) {
+		count = 0
+		res, err := Do(newRequest(t, ts.URL+"/down"))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		require.Equal(t, 4, count)
+	})
+
+	t.Run("no retry on 4xx", func(t *testing.T) {
+		count = 0
+		res, err := Do(newRequest(t, ts.URL+"/not-found"))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+		require.Equal(t, 1, count)
+	})
+
+	t.Run("retry on connection error", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		waits = nil
+		_, err := Do(newRequest(t, closed.URL))
+		require.Error(t, err)
+		require.Len(t, waits, 3)
+	})
+}
+
+func TestConfigure(t *testing.T) {
+	defer func() { require.NoError(t, Configure(Config{})) }()
+
+	t.Run("ca bundle", func(t *testing.T) {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer ts.Close()
+
+		// untrusted, and not retried
+		require.NoError(t, Configure(Config{Retries: 3}))
+		_, err := Do(newRequest(t, ts.URL))
+		require.Error(t, err)
+
+		bundle := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(bundle,
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0644))
+		require.NoError(t, Configure(Config{CABundle: bundle}))
+		res, err := Do(newRequest(t, ts.URL))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		var proxied string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+		}))
+		defer proxy.Close()
+
+		require.NoError(t, Configure(Config{Proxy: proxy.URL}))
+		res, err := Do(newRequest(t, "http://getmesh.example.com/manifest.json"))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "http://getmesh.example.com/manifest.json", proxied)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.Error(t, Configure(Config{Proxy: "proxy"}))
+		require.Error(t, Configure(Config{CABundle: filepath.Join(t.TempDir(), "not-exist.pem")}))
+
+		invalid := filepath.Join(t.TempDir(), "invalid.pem")
+		require.NoError(t, os.WriteFile(invalid, []byte("not a certificate"), 0644))
+		require.Error(t, Configure(Config{CABundle: invalid}))
+
+		require.Error(t, Configure(Config{Retries: -1}))
+	})
+}
@@ -17,6 +17,8 @@ package util
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -59,3 +61,29 @@ func GetK8sClient() (*kubernetes.Clientset, error) {
 
 	return kubeCli, nil
 }
+
+// GetK8sServerVersion returns the minor version of the cluster in the form of "x.y", e.g. "1.26".
+func GetK8sServerVersion() (string, error) {
+	kubeCli, err := GetK8sClient()
+	if err != nil {
+		return "", err
+	}
+
+	info, err := kubeCli.ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve Kubernetes server version: %w", err)
+	}
+	return formatK8sMinorVersion(info.Major, info.Minor)
+}
+
+// some providers append "+" to the minor version, e.g. "26+" on EKS
+func formatK8sMinorVersion(major, minor string) (string, error) {
+	minor = strings.TrimRight(minor, "+")
+	if _, err := strconv.Atoi(major); err != nil {
+		return "", fmt.Errorf("invalid Kubernetes major version %q", major)
+	}
+	if _, err := strconv.Atoi(minor); err != nil {
+		return "", fmt.Errorf("invalid Kubernetes minor version %q", minor)
+	}
+	return major + "." + minor, nil
+}
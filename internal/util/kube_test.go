@@ -46,3 +46,21 @@ func TestGetKubeConfigLocation(t *testing.T) {
 		KubeConfig = "" //cleanup
 	})
 }
+
+func Test_formatK8sMinorVersion(t *testing.T) {
+	for _, c := range []struct {
+		major, minor, exp string
+	}{
+		{major: "1", minor: "26", exp: "1.26"},
+		{major: "1", minor: "27+", exp: "1.27"},
+	} {
+		actual, err := formatK8sMinorVersion(c.major, c.minor)
+		require.NoError(t, err)
+		require.Equal(t, c.exp, actual)
+	}
+
+	for _, c := range [][2]string{{"", "26"}, {"1", ""}, {"1", "x"}} {
+		_, err := formatK8sMinorVersion(c[0], c[1])
+		require.Error(t, err, c)
+	}
+}
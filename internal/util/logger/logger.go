@@ -25,22 +25,41 @@ import (
 var l = &logger{w: os.Stdout, mux: &sync.Mutex{}}
 
 type logger struct {
-	w   io.Writer
-	mux *sync.Mutex
+	w io.Writer
+	// the diagnostics are written here instead of w when set
+	diag io.Writer
+	mux  *sync.Mutex
+	// serializes the writes from the concurrent goroutines, e.g. fetching multiple distributions
+	writeMux sync.Mutex
+}
+
+func (l *logger) write(diag bool, msg string) {
+	l.writeMux.Lock()
+	defer l.writeMux.Unlock()
+	w := l.w
+	if diag && l.diag != nil {
+		w = l.diag
+	}
+	_, _ = w.Write([]byte(msg))
 }
 
 func Infof(format string, v ...interface{}) {
-	_, _ = l.w.Write([]byte(fmt.Sprintf(format, v...)))
+	l.write(true, fmt.Sprintf(format, v...))
 }
 
 func Warnf(format string, v ...interface{}) {
 	base := fmt.Sprintf("[WARNING] %s", format)
-	_, _ = l.w.Write([]byte(fmt.Sprintf(base, v...)))
+	l.write(true, fmt.Sprintf(base, v...))
 }
 
 func Errorf(format string, v ...interface{}) {
 	base := fmt.Sprintf("[ERROR] %s", format)
-	_, _ = l.w.Write([]byte(fmt.Sprintf(base, v...)))
+	l.write(true, fmt.Sprintf(base, v...))
+}
+
+// Outputf writes the command output such as json and yaml, which is never sent to the diagnostic writer.
+func Outputf(format string, v ...interface{}) {
+	l.write(false, fmt.Sprintf(format, v...))
 }
 
 func Lock() {
@@ -52,6 +71,8 @@ func Unlock() {
 }
 
 func SetWriter(w io.Writer) {
+	l.writeMux.Lock()
+	defer l.writeMux.Unlock()
 	l.w = w
 }
 
@@ -59,11 +80,20 @@ func GetWriter() io.Writer {
 	return l.w
 }
 
+// SetDiagnosticWriter sends Infof, Warnf and Errorf to w, so that the output written by Outputf is kept parsable.
+// nil sends them to the writer of the output.
+func SetDiagnosticWriter(w io.Writer) {
+	l.writeMux.Lock()
+	defer l.writeMux.Unlock()
+	l.diag = w
+}
+
 func ExecuteWithLock(f func()) *bytes.Buffer {
 	Lock()
 	defer Unlock()
 	buf := new(bytes.Buffer)
 	SetWriter(buf)
+	SetDiagnosticWriter(nil)
 	f()
 	return buf
 }
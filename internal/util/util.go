@@ -17,9 +17,12 @@ package util
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+
+	"github.com/olekukonko/tablewriter"
 )
 
 const (
@@ -67,3 +70,43 @@ func HandleMultipleErrors(errorList []error) error {
 
 	return errors.New(toPrintErrorCollection)
 }
+
+// WriteFileAtomic writes raw into the temporary file in the same directory then renames it to path,
+// so that readers never see a partially written file.
+func WriteFileAtomic(path string, raw []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// FlushTable renders the data in the borderless table padded with tabs, which is shared by the table outputs.
+func FlushTable(table *tablewriter.Table, data [][]string) {
+	table.SetAutoWrapText(true)
+	table.SetColWidth(tablewriter.MAX_ROW_WIDTH * 4)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_CENTER)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t") // pad with tabs
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data) // Add Bulk Data
+	table.Render()
+}
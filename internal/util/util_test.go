@@ -76,3 +76,25 @@ func TestGetmeshHomeDir(t *testing.T) {
 		require.Equal(t, filepath.Join(usr.HomeDir, getmeshDirname), dir)
 	})
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0600))
+
+	require.NoError(t, WriteFileAtomic(path, []byte("new"), 0644))
+	actual, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(actual))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// no temporary file is left
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.Error(t, WriteFileAtomic(filepath.Join(dir, "non-existent", "config.json"), nil, 0644))
+}
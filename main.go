@@ -16,11 +16,13 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	// required for authentication against GKE
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/tetratelabs/getmesh/cmd"
 	"github.com/tetratelabs/getmesh/internal/getmesh"
+	"github.com/tetratelabs/getmesh/internal/istioctl"
 	"github.com/tetratelabs/getmesh/internal/util"
 	"github.com/tetratelabs/getmesh/internal/util/logger"
 )
@@ -42,5 +44,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	// getmesh is invoked via the istioctl shim on PATH
+	if filepath.Base(os.Args[0]) == istioctl.ShimName {
+		cmd.ExecuteIstioctlShim(version, hd, os.Args[1:])
+		return
+	}
+
 	cmd.Execute(version, hd)
 }